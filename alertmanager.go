@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// alertmanagerPayload is the subset of the Alertmanager webhook payload we need.
+type alertmanagerPayload struct {
+	Status string              `json:"status"`
+	Alerts []alertmanagerAlert `json:"alerts"`
+}
+
+type alertmanagerAlert struct {
+	Status string            `json:"status"`
+	Labels map[string]string `json:"labels"`
+}
+
+// alertmanagerHandler serves POST /alertmanager[/{name}], an Alertmanager webhook
+// receiver for always-firing "Watchdog" alerts. Every firing alert counts as a
+// heartbeat; resolved notifications are ignored. The device name is taken from the
+// path, or else from the alert's "device" label, falling back to "alertname".
+func alertmanagerHandler(cfg *config.Config, notifiers []notify.Notifier, basePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		pathName := strings.Trim(strings.TrimPrefix(r.URL.Path, basePath+"/alertmanager"), "/")
+		var payload alertmanagerPayload
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHeartbeatBody)).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if _, err := w.Write([]byte("Invalid Alertmanager payload")); err != nil {
+				log.Printf("Write error: %v", err)
+			}
+			return
+		}
+		if payload.Status == "resolved" {
+			log.Printf("Ignoring resolved Alertmanager notification")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		names := alertmanagerDevices(payload, pathName)
+		for _, name := range names {
+			if err := authorizeCompact(cfg, name, bearerToken(r), "", ""); err != nil {
+				log.Printf("Security event: rejected Alertmanager heartbeat for %s from %s: %v", name, r.RemoteAddr, err)
+				w.WriteHeader(http.StatusUnauthorized)
+				if _, err := w.Write([]byte("Missing or invalid device token")); err != nil {
+					log.Printf("Write error: %v", err)
+				}
+				return
+			}
+		}
+		for _, name := range names {
+			log.Printf("Received Alertmanager heartbeat from client: %s", name)
+			recordHeartbeat(cfg, notifiers, name)
+		}
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte("OK")); err != nil {
+			log.Printf("Write error: %v", err)
+		}
+	}
+}
+
+// alertmanagerDevices returns the distinct device names of all firing alerts.
+func alertmanagerDevices(payload alertmanagerPayload, pathName string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, a := range payload.Alerts {
+		if a.Status != "firing" {
+			continue
+		}
+		name := pathName
+		if name == "" {
+			name = a.Labels["device"]
+		}
+		if name == "" {
+			name = a.Labels["alertname"]
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+)
+
+const watchdogPayload = `{
+  "version": "4",
+  "status": "firing",
+  "receiver": "dead-mans-switch",
+  "alerts": [
+    {"status": "firing", "labels": {"alertname": "Watchdog", "severity": "none"}},
+    {"status": "firing", "labels": {"alertname": "Watchdog", "device": "prod-alertmanager"}},
+    {"status": "resolved", "labels": {"alertname": "Other"}}
+  ]
+}`
+
+func TestAlertmanagerDevices(t *testing.T) {
+	payload := alertmanagerPayload{Alerts: []alertmanagerAlert{
+		{Status: "firing", Labels: map[string]string{"alertname": "Watchdog"}},
+		{Status: "firing", Labels: map[string]string{"alertname": "Watchdog", "device": "am-eu"}},
+		{Status: "resolved", Labels: map[string]string{"alertname": "Gone"}},
+	}}
+	if got := alertmanagerDevices(payload, ""); strings.Join(got, ",") != "Watchdog,am-eu" {
+		t.Errorf("names from labels = %v", got)
+	}
+	if got := alertmanagerDevices(payload, "am"); strings.Join(got, ",") != "am" {
+		t.Errorf("names from path = %v", got)
+	}
+}
+
+func TestAlertmanagerEndpoint(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-alertmanager.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	ts := httptest.NewServer(newMux(&config.Config{TimeoutSeconds: 600}, nil, ""))
+	defer ts.Close()
+
+	post := func(path, body string) int {
+		t.Helper()
+		resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := post("/alertmanager/am-main", watchdogPayload); got != http.StatusOK {
+		t.Fatalf("expected 200, got %d", got)
+	}
+	if _, ok := dbInstance.Get("am-main"); !ok {
+		t.Error("expected heartbeat for path name")
+	}
+
+	if got := post("/alertmanager", watchdogPayload); got != http.StatusOK {
+		t.Fatalf("expected 200, got %d", got)
+	}
+	for _, name := range []string{"Watchdog", "prod-alertmanager"} {
+		if _, ok := dbInstance.Get(name); !ok {
+			t.Errorf("expected heartbeat for label-derived name %s", name)
+		}
+	}
+	if _, ok := dbInstance.Get("Other"); ok {
+		t.Error("resolved alert must not be recorded")
+	}
+
+	resolved := strings.Replace(watchdogPayload, `"status": "firing",
+  "receiver"`, `"status": "resolved",
+  "receiver"`, 1)
+	if got := post("/alertmanager/am-resolved", resolved); got != http.StatusOK {
+		t.Fatalf("expected 200, got %d", got)
+	}
+	if _, ok := dbInstance.Get("am-resolved"); ok {
+		t.Error("resolved notification must be ignored")
+	}
+
+	if got := post("/alertmanager/am-main", "not json"); got != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid payload, got %d", got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+type batchEntry struct {
+	Name    string          `json:"name"`
+	Token   string          `json:"token,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type batchRejection struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+type batchResult struct {
+	Accepted int              `json:"accepted"`
+	Rejected []batchRejection `json:"rejected"`
+}
+
+// batchHeartbeatHandler serves POST /heartbeats/batch, which lets a gateway report
+// many clients at once. All accepted heartbeats are written in one transaction and
+// SSE clients are refreshed once per request.
+func batchHeartbeatHandler(cfg *config.Config, notifiers []notify.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			Heartbeats []batchEntry `json:"heartbeats"`
+		}
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHeartbeatBody))
+		if err == nil {
+			err = json.Unmarshal(raw, &body)
+		}
+		if err != nil || len(body.Heartbeats) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			if _, err := w.Write([]byte("Missing or invalid 'heartbeats' in body")); err != nil {
+				log.Printf("Write error: %v", err)
+			}
+			return
+		}
+
+		now := time.Now()
+		result := batchResult{Rejected: []batchRejection{}}
+		var accepted []db.ClientHeartbeat
+		for _, e := range body.Heartbeats {
+			if reason := rejectBatchEntry(cfg, e, bearerToken(r)); reason != "" {
+				log.Printf("Rejected batch heartbeat for %q from %s: %s", e.Name, r.RemoteAddr, reason)
+				result.Rejected = append(result.Rejected, batchRejection{Name: e.Name, Error: reason})
+				continue
+			}
+			accepted = append(accepted, db.ClientHeartbeat{Name: e.Name, Timestamp: now, Payload: e.Payload})
+		}
+
+		if len(accepted) > 0 {
+			recovered, err := dbInstance.UpdateHeartbeats(accepted)
+			if err != nil {
+				log.Printf("DB batch update error: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				if _, err := w.Write([]byte("DB error")); err != nil {
+					log.Printf("Write error: %v", err)
+				}
+				return
+			}
+			result.Accepted = len(accepted)
+			log.Printf("Stored batch of %d heartbeats to DB (%d rejected)", len(accepted), len(result.Rejected))
+			for _, ch := range accepted {
+				publishEvent(eventHeartbeat, ch)
+			}
+			broadcastDeviceTable(cfg)
+			for _, name := range recovered {
+				publishEvent(eventRecovery, db.ClientHeartbeat{Name: name, Timestamp: now})
+				notifyRecovery(cfg, notifiers, name)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.Printf("Encode error: %v", err)
+		}
+	}
+}
+
+// rejectBatchEntry returns why e may not be recorded, or "" if it is acceptable.
+// The entry's own token takes precedence over the request's bearer token.
+func rejectBatchEntry(cfg *config.Config, e batchEntry, bearer string) string {
+	if e.Name == "" {
+		return "missing name"
+	}
+	if d, ok := cfg.Device(e.Name); ok && d.HMACSecret != "" {
+		// A batch cannot carry per-device signatures
+		return "device requires a signed heartbeat"
+	}
+	if cfg.RequireToken {
+		token := e.Token
+		if token == "" {
+			token = bearer
+		}
+		if !dbInstance.VerifyToken(e.Name, token) {
+			return "missing or invalid device token"
+		}
+	}
+	return ""
+}
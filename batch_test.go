@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+func TestBatchHeartbeatEndpoint(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-batch.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+	if err := dbInstance.UpdateHeartbeat("sensor1", time.Now().Add(-time.Hour), true); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	rec := &recordingNotifier{}
+	cfg := &config.Config{
+		TimeoutSeconds: 600,
+		Devices:        []config.Device{{Name: "signed", HMACSecret: "s3cret"}},
+	}
+	ts := httptest.NewServer(newMux(cfg, []notify.Notifier{rec}, ""))
+	defer ts.Close()
+
+	body := `{"heartbeats":[{"name":"sensor1"},{"name":"sensor2","payload":{"temp":21}},{"name":""},{"name":"signed"}]}`
+	resp, err := http.Post(ts.URL+"/heartbeats/batch", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var result batchResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if result.Accepted != 2 || len(result.Rejected) != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	beats, _ := dbInstance.GetAllHeartbeats()
+	if beats["sensor1"].Missing {
+		t.Error("sensor1 should no longer be missing")
+	}
+	if string(beats["sensor2"].Payload) != `{"temp":21}` {
+		t.Errorf("sensor2 payload not stored: %s", beats["sensor2"].Payload)
+	}
+	if _, ok := beats["signed"]; ok {
+		t.Error("device with HMAC secret must not be accepted in a batch")
+	}
+	if rec.count() != 1 {
+		t.Errorf("expected 1 recovery notification, got %d", rec.count())
+	}
+}
+
+func TestBatchHeartbeatEndpointRequireToken(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-batch-token.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+	_ = dbInstance.SetToken("sensor1", "token-1")
+	_ = dbInstance.SetToken("sensor2", "token-2")
+
+	cfg := &config.Config{TimeoutSeconds: 600, RequireToken: true}
+	ts := httptest.NewServer(newMux(cfg, nil, ""))
+	defer ts.Close()
+
+	body := `{"heartbeats":[{"name":"sensor1","token":"token-1"},{"name":"sensor2","token":"token-1"},{"name":"sensor2"}]}`
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/heartbeats/batch", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token-2")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var result batchResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	// sensor1 with own token and sensor2 with the bearer token pass; sensor2 with sensor1's token fails
+	if result.Accepted != 2 || len(result.Rejected) != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestBatchHeartbeatEndpointBadRequest(t *testing.T) {
+	cfg := &config.Config{TimeoutSeconds: 600}
+	ts := httptest.NewServer(newMux(cfg, nil, ""))
+	defer ts.Close()
+
+	for _, body := range []string{`not json`, `{"heartbeats":[]}`} {
+		resp, err := http.Post(ts.URL+"/heartbeats/batch", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("body %q: expected 400, got %d", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestBatchHeartbeatKeepsInheritedState(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-batch-state.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	now := time.Now().Truncate(time.Second)
+	expires := now.Add(48 * time.Hour)
+	if err := dbInstance.UpdateHeartbeat("agent", now.Add(-time.Minute), false); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if err := dbInstance.SetConnected("agent", true, now); err != nil {
+		t.Fatalf("set connected: %v", err)
+	}
+	if err := dbInstance.PutHeartbeat(db.ClientHeartbeat{Name: "web-cert", Timestamp: now, ExpiresAt: &expires, ExpiryNotified: 3}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	cfg := &config.Config{TimeoutSeconds: 600}
+	ts := httptest.NewServer(newMux(cfg, nil, ""))
+	defer ts.Close()
+	resp, err := http.Post(ts.URL+"/heartbeats/batch", "application/json", strings.NewReader(`{"heartbeats":[{"name":"agent"},{"name":"web-cert"}]}`))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	agent, _ := dbInstance.Get("agent")
+	if !agent.Connection || agent.ConnectedSince == nil || !agent.ConnectedSince.Equal(now) {
+		t.Errorf("batch heartbeat dropped the agent connection: %+v", agent)
+	}
+	cert, _ := dbInstance.Get("web-cert")
+	if cert.ExpiresAt == nil || !cert.ExpiresAt.Equal(expires) || cert.ExpiryNotified != 3 {
+		t.Errorf("batch heartbeat dropped the certificate expiry state: %+v", cert)
+	}
+
+	// A connected agent must not time out, even once the batch heartbeat is stale
+	agent.Timestamp = now.Add(-time.Hour)
+	if err := dbInstance.PutHeartbeat(agent); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	checkTimeouts(cfg, nil)
+	if agent, _ := dbInstance.Get("agent"); agent.Missing {
+		t.Error("connected agent was marked missing after a batch heartbeat")
+	}
+}
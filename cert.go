@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// newCertProber returns a check that reads the certificate chain served at
+// d.Address or stored at d.Path and reports its earliest expiry. An expired
+// certificate is recorded as a failure.
+func newCertProber(d config.Device) (prober, error) {
+	if (d.Address == "") == (d.Path == "") {
+		return nil, errors.New("cert check needs either an address or a path")
+	}
+	fetch := func(ctx context.Context) ([]*x509.Certificate, error) {
+		return readPEMCertificates(d.Path)
+	}
+	if d.Address != "" {
+		host, _, err := net.SplitHostPort(d.Address)
+		if err != nil {
+			return nil, fmt.Errorf("cert check address: %w", err)
+		}
+		fetch = func(ctx context.Context) ([]*x509.Certificate, error) {
+			return fetchCertificates(ctx, d.Address, host)
+		}
+	}
+	return func(ctx context.Context) (heartbeatStatus, error) {
+		var st heartbeatStatus
+		certs, err := fetch(ctx)
+		if err != nil {
+			return st, err
+		}
+		expires := certs[0].NotAfter
+		for _, c := range certs[1:] {
+			if c.NotAfter.Before(expires) {
+				expires = c.NotAfter
+			}
+		}
+		st.ExpiresAt = &expires
+		if !time.Now().Before(expires) {
+			st.Failed = true
+			st.Message = "certificate expired at " + expires.UTC().Format(time.RFC3339)
+		}
+		return st, nil
+	}, nil
+}
+
+// fetchCertificates returns the certificate chain presented by the TLS server at addr.
+func fetchCertificates(ctx context.Context, addr, serverName string) ([]*x509.Certificate, error) {
+	dialer := tls.Dialer{Config: &tls.Config{
+		ServerName: serverName,
+		// Only the expiry is checked, so self-signed and internal CAs are fine
+		InsecureSkipVerify: true,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("server sent no certificate")
+	}
+	return certs, nil
+}
+
+// readPEMCertificates parses all certificates in the PEM file at path.
+func readPEMCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return certs, nil
+}
+
+// daysUntil returns the number of whole days left until t.
+func daysUntil(t, now time.Time) int {
+	return int(t.Sub(now).Hours() / 24)
+}
+
+// formatExpiry describes a certificate expiry for the dashboard.
+func formatExpiry(expires, now time.Time) string {
+	days := daysUntil(expires, now)
+	switch {
+	case !now.Before(expires):
+		return "certificate expired"
+	case days == 0:
+		return "certificate expires today"
+	case days == 1:
+		return "certificate expires in 1 day"
+	default:
+		return "certificate expires in " + strconv.Itoa(days) + " days"
+	}
+}
+
+// notifyExpiry sends an expiry notification when the certificate of d crossed a
+// threshold that was not notified yet.
+func notifyExpiry(cfg *config.Config, notifiers []notify.Notifier, d config.Device) {
+	ch, ok := dbInstance.Get(d.Name)
+	if !ok || ch.ExpiresAt == nil {
+		return
+	}
+	days := daysUntil(*ch.ExpiresAt, time.Now())
+	if days < 0 {
+		// Expired certificates are reported as failures
+		return
+	}
+	thresholds := append([]int(nil), d.ExpiryThresholdDays()...)
+	sort.Ints(thresholds)
+	crossed := 0
+	for _, t := range thresholds {
+		if days <= t {
+			crossed = t
+			break
+		}
+	}
+	if crossed == 0 || (ch.ExpiryNotified != 0 && ch.ExpiryNotified <= crossed) {
+		return
+	}
+	msg := cfg.NotificationMessages.Expiry
+	if msg == "" {
+		msg = "Certificate of {{name}} expires in {{days}} days at {{expires}}."
+	}
+	msg = strings.ReplaceAll(msg, "{{name}}", d.Name)
+	msg = strings.ReplaceAll(msg, "{{days}}", strconv.Itoa(days))
+	msg = strings.ReplaceAll(msg, "{{expires}}", ch.ExpiresAt.UTC().Format(time.RFC3339))
+	sendNotification(notifiers, notify.Event{
+		Type:     notify.EventExpiry,
+		Subject:  "Dead Man's Switch Certificate Expiry",
+		Message:  msg,
+		Device:   d.Name,
+		Status:   "expiring",
+		LastSeen: ch.Timestamp,
+	})
+	if err := dbInstance.SetExpiryNotified(d.Name, crossed); err != nil {
+		log.Printf("DB update error for %s: %v", d.Name, err)
+	}
+}
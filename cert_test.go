@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// writeTestCert writes a self-signed certificate expiring at notAfter as PEM file.
+func writeTestCert(t *testing.T, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	return path
+}
+
+func TestCertProberValidation(t *testing.T) {
+	for _, d := range []config.Device{
+		{Type: "cert"},
+		{Type: "cert", Address: "example.com:443", Path: "/tmp/cert.pem"},
+		{Type: "cert", Address: "example.com"},
+	} {
+		if _, err := newProber(d); err == nil {
+			t.Errorf("expected error for %+v", d)
+		}
+	}
+}
+
+func TestCertProberFile(t *testing.T) {
+	notAfter := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second)
+	check, err := newProber(config.Device{Type: "cert", Path: writeTestCert(t, notAfter)})
+	if err != nil {
+		t.Fatalf("newProber: %v", err)
+	}
+	st, err := check(context.Background())
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if st.Failed || st.ExpiresAt == nil || !st.ExpiresAt.Equal(notAfter) {
+		t.Errorf("unexpected status: %+v", st)
+	}
+
+	check, _ = newProber(config.Device{Type: "cert", Path: writeTestCert(t, time.Now().Add(-time.Hour))})
+	if st, err := check(context.Background()); err != nil || !st.Failed {
+		t.Errorf("expected expired certificate to fail, got %+v, %v", st, err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("no certificate here"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	check, _ = newProber(config.Device{Type: "cert", Path: empty})
+	if _, err := check(context.Background()); err == nil {
+		t.Error("expected error for file without certificate")
+	}
+}
+
+func TestCertProberAddress(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	check, err := newProber(config.Device{Type: "cert", Address: srv.Listener.Addr().String()})
+	if err != nil {
+		t.Fatalf("newProber: %v", err)
+	}
+	st, err := check(context.Background())
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if st.ExpiresAt == nil || !st.ExpiresAt.Equal(srv.Certificate().NotAfter) {
+		t.Errorf("expected expiry %v, got %+v", srv.Certificate().NotAfter, st)
+	}
+}
+
+func TestFormatExpiry(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		expires time.Time
+		want    string
+	}{
+		{now.Add(-time.Minute), "certificate expired"},
+		{now.Add(time.Hour), "certificate expires today"},
+		{now.Add(36 * time.Hour), "certificate expires in 1 day"},
+		{now.Add(30*24*time.Hour + time.Hour), "certificate expires in 30 days"},
+	}
+	for _, tt := range tests {
+		if got := formatExpiry(tt.expires, now); got != tt.want {
+			t.Errorf("formatExpiry(%v) = %q, want %q", tt.expires.Sub(now), got, tt.want)
+		}
+	}
+}
+
+func TestNotifyExpiryThresholds(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-cert.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{TimeoutSeconds: 600}
+	d := config.Device{Name: "web-cert", Type: "cert"}
+	rec := &recordingNotifier{}
+	notifiers := []notify.Notifier{rec}
+	check := func(days int) {
+		t.Helper()
+		expires := time.Now().Add(time.Duration(days)*24*time.Hour + time.Hour).Truncate(time.Second)
+		recordStatus(cfg, notifiers, d.Name, heartbeatStatus{ExpiresAt: &expires})
+		notifyExpiry(cfg, notifiers, d)
+	}
+
+	check(60)
+	if rec.count() != 0 {
+		t.Fatalf("no notification expected 60 days out, got %v", rec.subjects)
+	}
+	check(20)
+	check(20)
+	if rec.count() != 1 || !strings.Contains(rec.messages[0], "expires in 20 days") {
+		t.Fatalf("expected one 30-day notification, got %v", rec.messages)
+	}
+	// Jumping past several thresholds notifies once for the smallest
+	check(2)
+	if rec.count() != 2 {
+		t.Fatalf("expected a second notification, got %v", rec.messages)
+	}
+	if ch, _ := dbInstance.Get(d.Name); ch.ExpiryNotified != 3 {
+		t.Errorf("expected 3-day threshold recorded, got %d", ch.ExpiryNotified)
+	}
+	// A renewed certificate starts over
+	check(90)
+	check(25)
+	if rec.count() != 3 {
+		t.Errorf("expected notification after renewal, got %v", rec.messages)
+	}
+	ch, _ := dbInstance.Get(d.Name)
+	if html := generateDeviceTable(cfg, map[string]db.ClientHeartbeat{d.Name: ch}); !strings.Contains(html, "certificate expires in 25 days") {
+		t.Error("expected days to expiry in device table")
+	}
+}
@@ -17,6 +17,8 @@ type NotificationChannel struct {
 type NotificationMessages struct {
 	Timeout  string `yaml:"timeout" envconfig:"NOTIFY_TIMEOUT_MSG"`
 	Recovery string `yaml:"recovery" envconfig:"NOTIFY_RECOVERY_MSG"`
+	Failure  string `yaml:"failure" envconfig:"NOTIFY_FAILURE_MSG"`
+	Expiry   string `yaml:"expiry" envconfig:"NOTIFY_EXPIRY_MSG"`
 }
 
 type SecurityHeaders struct {
@@ -25,19 +27,131 @@ type SecurityHeaders struct {
 	ReferrerPolicy      string `yaml:"referrer_policy" envconfig:"REFERRER_POLICY"`
 }
 
+// MQTT configures the optional MQTT heartbeat subscriber. It is disabled if Broker is empty.
+type MQTT struct {
+	Broker   string   `yaml:"broker" envconfig:"BROKER"`
+	ClientID string   `yaml:"client_id" envconfig:"CLIENT_ID"`
+	Username string   `yaml:"username" envconfig:"USERNAME"`
+	Password string   `yaml:"password" envconfig:"PASSWORD"`
+	Topics   []string `yaml:"topics" envconfig:"TOPICS"`
+}
+
+// InboundEmail configures the optional SMTP receiver that turns mail to
+// <device>@<Domain> into heartbeats. It is disabled if ListenAddr is empty.
+type InboundEmail struct {
+	ListenAddr      string   `yaml:"listen_addr" envconfig:"LISTEN_ADDR"`
+	Domain          string   `yaml:"domain" envconfig:"DOMAIN"`
+	FailureKeywords []string `yaml:"failure_keywords" envconfig:"FAILURE_KEYWORDS"`
+}
+
+// Syslog configures the optional syslog receiver, listening on UDP and TCP.
+// It is disabled if ListenAddr is empty.
+type Syslog struct {
+	ListenAddr string       `yaml:"listen_addr" envconfig:"LISTEN_ADDR"`
+	Rules      []SyslogRule `yaml:"rules"`
+}
+
+// SyslogRule maps matching syslog messages to a device. Hostname, AppName and
+// Message are regular expressions; empty ones match anything. Device may use the
+// {{hostname}} and {{app_name}} placeholders and defaults to {{hostname}}.
+// Messages matching FailurePattern are recorded as failures.
+type SyslogRule struct {
+	Device         string `yaml:"device"`
+	Hostname       string `yaml:"hostname"`
+	AppName        string `yaml:"app_name"`
+	Message        string `yaml:"message"`
+	FailurePattern string `yaml:"failure_pattern"`
+}
+
+// InboundWebhook maps an arbitrary JSON webhook payload, received at
+// /webhooks/<Path>, onto a heartbeat. Field expressions are dot paths such as
+// "build.status" or "$.commits[0].message".
+type InboundWebhook struct {
+	Path          string   `yaml:"path"`
+	Name          string   `yaml:"name"`
+	NameField     string   `yaml:"name_field"`
+	StatusField   string   `yaml:"status_field"`
+	SuccessValues []string `yaml:"success_values"`
+	FailureValues []string `yaml:"failure_values"`
+	IgnoreValues  []string `yaml:"ignore_values"`
+	MessageField  string   `yaml:"message_field"`
+}
+
+// Device holds settings for a single, explicitly defined device. Devices with a
+// Type are probed by the server itself instead of sending heartbeats:
+// "http" requests URL, "tcp" connects to Address, "cert" checks the expiry of
+// the TLS certificate served at Address or stored in the PEM file at Path and
+// "file" watches the modification time of the files matching the glob Path.
+type Device struct {
+	Name       string `yaml:"name"`
+	HMACSecret string `yaml:"hmac_secret"`
+	PingUUID   string `yaml:"ping_uuid"`
+
+	Type                string `yaml:"type"`
+	URL                 string `yaml:"url"`
+	ExpectedStatus      int    `yaml:"expected_status"`
+	BodyRegex           string `yaml:"body_regex"`
+	Address             string `yaml:"address"`
+	Path                string `yaml:"path"`
+	MinSize             int64  `yaml:"min_size"`
+	ExpiryThresholds    []int  `yaml:"expiry_thresholds_days"`
+	IntervalSeconds     int    `yaml:"interval_seconds"`
+	ProbeTimeoutSeconds int    `yaml:"probe_timeout_seconds"`
+}
+
+// ExpiryThresholdDays returns the days before certificate expiry at which to
+// notify, 30, 14 and 3 by default.
+func (d Device) ExpiryThresholdDays() []int {
+	if len(d.ExpiryThresholds) == 0 {
+		return []int{30, 14, 3}
+	}
+	return d.ExpiryThresholds
+}
+
+// Interval returns how often a probed device is checked, one minute by default.
+func (d Device) Interval() time.Duration {
+	if d.IntervalSeconds <= 0 {
+		return time.Minute
+	}
+	return time.Duration(d.IntervalSeconds) * time.Second
+}
+
+// ProbeTimeout returns the time limit for a single probe, ten seconds by default.
+func (d Device) ProbeTimeout() time.Duration {
+	if d.ProbeTimeoutSeconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(d.ProbeTimeoutSeconds) * time.Second
+}
+
 type Config struct {
-	ListenAddr           string                `yaml:"listen_addr" envconfig:"LISTEN_ADDR"`
-	TimeoutSeconds       int                   `yaml:"timeout_seconds" envconfig:"TIMEOUT_SECONDS"`
-	Invert               bool                  `yaml:"invert" envconfig:"INVERT"`
-	NotificationChannels []NotificationChannel `yaml:"notification_channels"`
-	NotificationMessages NotificationMessages  `yaml:"notification_messages"`
-	SecurityHeaders      SecurityHeaders       `yaml:"security_headers" envconfig:""`
+	ListenAddr              string                `yaml:"listen_addr" envconfig:"LISTEN_ADDR"`
+	TimeoutSeconds          int                   `yaml:"timeout_seconds" envconfig:"TIMEOUT_SECONDS"`
+	Invert                  bool                  `yaml:"invert" envconfig:"INVERT"`
+	RequireToken            bool                  `yaml:"require_token" envconfig:"REQUIRE_TOKEN"`
+	AdminToken              string                `yaml:"admin_token" envconfig:"ADMIN_TOKEN"`
+	HMACReplayWindowSeconds int                   `yaml:"hmac_replay_window_seconds" envconfig:"HMAC_REPLAY_WINDOW_SECONDS"`
+	WebSocketGraceSeconds   int                   `yaml:"websocket_grace_seconds" envconfig:"WEBSOCKET_GRACE_SECONDS"`
+	LogRetention            int                   `yaml:"log_retention" envconfig:"LOG_RETENTION"`
+	UDPListenAddr           string                `yaml:"udp_listen_addr" envconfig:"UDP_LISTEN_ADDR"`
+	GRPCListenAddr          string                `yaml:"grpc_listen_addr" envconfig:"GRPC_LISTEN_ADDR"`
+	MQTT                    MQTT                  `yaml:"mqtt" envconfig:"MQTT"`
+	InboundEmail            InboundEmail          `yaml:"inbound_email" envconfig:"INBOUND_EMAIL"`
+	InboundWebhooks         []InboundWebhook      `yaml:"inbound_webhooks"`
+	Syslog                  Syslog                `yaml:"syslog" envconfig:"SYSLOG"`
+	Devices                 []Device              `yaml:"devices"`
+	NotificationChannels    []NotificationChannel `yaml:"notification_channels"`
+	NotificationMessages    NotificationMessages  `yaml:"notification_messages"`
+	SecurityHeaders         SecurityHeaders       `yaml:"security_headers" envconfig:""`
 }
 
 func LoadConfig(path string) (*Config, error) {
 	cfg := &Config{
-		ListenAddr:     ":8080",
-		TimeoutSeconds: 600,
+		ListenAddr:              ":8080",
+		TimeoutSeconds:          600,
+		HMACReplayWindowSeconds: 300,
+		WebSocketGraceSeconds:   60,
+		LogRetention:            10,
 		SecurityHeaders: SecurityHeaders{
 			XContentTypeOptions: "nosniff",
 			XFrameOptions:       "DENY",
@@ -66,12 +180,53 @@ func (c *Config) Timeout() time.Duration {
 	return time.Duration(c.TimeoutSeconds) * time.Second
 }
 
+// ReplayWindow returns the maximum accepted age of a signed heartbeat.
+func (c *Config) ReplayWindow() time.Duration {
+	return time.Duration(c.HMACReplayWindowSeconds) * time.Second
+}
+
+// Device returns the device definition with the given name.
+func (c *Config) Device(name string) (Device, bool) {
+	for _, d := range c.Devices {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Device{}, false
+}
+
+// WebSocketGrace returns how long a disconnected WebSocket agent may stay away
+// before it is considered missing.
+func (c *Config) WebSocketGrace() time.Duration {
+	return time.Duration(c.WebSocketGraceSeconds) * time.Second
+}
+
+// InboundWebhook returns the inbound webhook served at /webhooks/<path>.
+func (c *Config) InboundWebhook(path string) (InboundWebhook, bool) {
+	for _, h := range c.InboundWebhooks {
+		if h.Path == path {
+			return h, true
+		}
+	}
+	return InboundWebhook{}, false
+}
+
+// DeviceByPingUUID returns the device definition with the given ping UUID.
+func (c *Config) DeviceByPingUUID(uuid string) (Device, bool) {
+	for _, d := range c.Devices {
+		if d.PingUUID != "" && strings.EqualFold(d.PingUUID, uuid) {
+			return d, true
+		}
+	}
+	return Device{}, false
+}
+
 // isSecretKey returns true if the property key should be masked.
 func isSecretKey(k string) bool {
 	switch {
-	case k == "bot_token":
+	case k == "bot_token", k == "headers", k == "webhook_url":
 		return true
-	case strings.Contains(k, "pass"), strings.Contains(k, "token"), strings.Contains(k, "secret"):
+	case strings.Contains(k, "pass"), strings.Contains(k, "token"), strings.Contains(k, "secret"), strings.Contains(k, "key"):
 		return true
 	}
 	return false
@@ -107,3 +262,21 @@ func MaskChannelSecrets(channels []NotificationChannel) []NotificationChannel {
 	}
 	return masked
 }
+
+// MaskDeviceSecrets returns a copy of devices with secret values masked.
+func MaskDeviceSecrets(devices []Device) []Device {
+	if len(devices) == 0 {
+		return nil
+	}
+	masked := make([]Device, len(devices))
+	for i, d := range devices {
+		masked[i] = d
+		if d.HMACSecret != "" {
+			masked[i].HMACSecret = MaskValue(d.HMACSecret)
+		}
+		if d.PingUUID != "" {
+			masked[i].PingUUID = MaskValue(d.PingUUID)
+		}
+	}
+	return masked
+}
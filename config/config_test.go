@@ -138,6 +138,13 @@ func TestMaskChannelSecrets(t *testing.T) {
 				"chat_id":   "-123456789",
 			},
 		},
+		{
+			Type: "pagerduty",
+			Properties: map[string]string{
+				"routing_key": "R0123456789abcdef0123456789abcdef",
+				"client_url":  "https://dms.example.com/",
+			},
+		},
 	}
 	masked := MaskChannelSecrets(channels)
 	// Original must not be mutated
@@ -160,6 +167,13 @@ func TestMaskChannelSecrets(t *testing.T) {
 	if masked[1].Properties["chat_id"] != "-123456789" {
 		t.Errorf("chat_id was masked: got %q", masked[1].Properties["chat_id"])
 	}
+	// API keys are secrets too
+	if masked[2].Properties["routing_key"] == channels[2].Properties["routing_key"] {
+		t.Error("routing_key not masked")
+	}
+	if masked[2].Properties["client_url"] != "https://dms.example.com/" {
+		t.Errorf("client_url was masked: got %q", masked[2].Properties["client_url"])
+	}
 }
 
 func TestMaskChannelSecretsEmpty(t *testing.T) {
@@ -235,3 +249,55 @@ func TestLoadConfigEnvOnly(t *testing.T) {
 		t.Error("expected INVERT to remain false")
 	}
 }
+
+func TestLoadConfigDevices(t *testing.T) {
+	path := testConfigPath(t, "test_devices.yaml")
+	if err := os.WriteFile(path, []byte(`devices:
+  - name: sensor1
+    hmac_secret: "supersecretvalue"
+  - name: sensor2
+`), 0644); err != nil {
+		t.Fatalf("failed to write test_devices.yaml: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if cfg.ReplayWindow() != 5*time.Minute {
+		t.Errorf("expected default replay window 5m, got %v", cfg.ReplayWindow())
+	}
+	d, ok := cfg.Device("sensor1")
+	if !ok || d.HMACSecret != "supersecretvalue" {
+		t.Errorf("sensor1 not loaded: %+v", d)
+	}
+	if _, ok := cfg.Device("unknown"); ok {
+		t.Error("expected unknown device to be absent")
+	}
+
+	masked := MaskDeviceSecrets(cfg.Devices)
+	if masked[0].HMACSecret != "sup***lue" {
+		t.Errorf("hmac_secret not masked: %q", masked[0].HMACSecret)
+	}
+	if masked[1].HMACSecret != "" {
+		t.Errorf("empty secret should stay empty, got %q", masked[1].HMACSecret)
+	}
+	if cfg.Devices[0].HMACSecret != "supersecretvalue" {
+		t.Error("MaskDeviceSecrets mutated original devices")
+	}
+}
+
+func TestDeviceByPingUUID(t *testing.T) {
+	cfg := &Config{Devices: []Device{
+		{Name: "plain"},
+		{Name: "backup", PingUUID: "5bf66975-d4c7-4bf5-bcc8-b8d8a82ea278"},
+	}}
+	if d, ok := cfg.DeviceByPingUUID("5BF66975-D4C7-4BF5-BCC8-B8D8A82EA278"); !ok || d.Name != "backup" {
+		t.Errorf("expected case-insensitive match for backup, got %+v, %v", d, ok)
+	}
+	if _, ok := cfg.DeviceByPingUUID(""); ok {
+		t.Error("empty UUID must not match devices without ping_uuid")
+	}
+	if masked := MaskDeviceSecrets(cfg.Devices); masked[1].PingUUID == cfg.Devices[1].PingUUID {
+		t.Error("ping_uuid not masked")
+	}
+}
@@ -1,16 +1,55 @@
 package db
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"go.etcd.io/bbolt"
 )
 
 type ClientHeartbeat struct {
-	Name      string    `json:"name"`
+	Name      string          `json:"name"`
+	Timestamp time.Time       `json:"timestamp"`
+	Missing   bool            `json:"missing"`
+	Failed    bool            `json:"failed,omitempty"`
+	Message   string          `json:"message,omitempty"`
+	StartedAt *time.Time      `json:"started_at,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	// Connection marks clients whose liveness follows an agent connection
+	// rather than periodic pings; ConnectedSince is nil while disconnected.
+	Connection     bool       `json:"connection,omitempty"`
+	ConnectedSince *time.Time `json:"connected_since,omitempty"`
+	// ExpiresAt is the certificate expiry of certificate-check devices;
+	// ExpiryNotified is the smallest threshold in days already notified for it.
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	ExpiryNotified int        `json:"expiry_notified,omitempty"`
+	// AcknowledgedAt and AcknowledgedBy record who acknowledged the current
+	// alert, e.g. a Pushover emergency notification.
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+}
+
+// Inherit copies the state that heartbeats do not carry, the agent connection
+// and the certificate expiry, from prev, the previous record of the same client.
+// The connection is only kept while the agent is connected; otherwise the
+// heartbeat came from another transport and the usual timeout applies again.
+func (ch *ClientHeartbeat) Inherit(prev ClientHeartbeat) {
+	if prev.ConnectedSince != nil {
+		ch.Connection, ch.ConnectedSince = prev.Connection, prev.ConnectedSince
+	}
+	ch.ExpiresAt, ch.ExpiryNotified = prev.ExpiresAt, prev.ExpiryNotified
+}
+
+// LogEntry is the output of a job run, sent along with a heartbeat.
+type LogEntry struct {
 	Timestamp time.Time `json:"timestamp"`
-	Missing   bool      `json:"missing"`
+	Failed    bool      `json:"failed,omitempty"`
+	Output    string    `json:"output"`
 }
 
 type DB struct {
@@ -26,18 +65,77 @@ func Open(path string) (*DB, error) {
 }
 
 func (d *DB) UpdateHeartbeat(name string, t time.Time, missing bool) error {
+	return d.PutHeartbeat(ClientHeartbeat{Name: name, Timestamp: t, Missing: missing})
+}
+
+// PutHeartbeat stores ch as the current state of the client named ch.Name.
+func (d *DB) PutHeartbeat(ch ClientHeartbeat) error {
 	return d.db.Update(func(tx *bbolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte("heartbeats"))
 		if err != nil {
 			return err
 		}
-		ch := ClientHeartbeat{Name: name, Timestamp: t, Missing: missing}
 		data, err := json.Marshal(ch)
 		if err != nil {
 			return err
 		}
-		return b.Put([]byte(name), data)
+		return b.Put([]byte(ch.Name), data)
+	})
+}
+
+// InsertHeartbeat stores ch unless a record for ch.Name already exists.
+func (d *DB) InsertHeartbeat(ch ClientHeartbeat) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("heartbeats"))
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(ch.Name)) != nil {
+			return nil
+		}
+		data, err := json.Marshal(ch)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(ch.Name), data)
+	})
+}
+
+// UpdateHeartbeats stores several heartbeats in a single transaction, clearing their
+// missing and failed state and keeping the state inherited from the previous records.
+// It returns the names of clients that were marked missing or failed before.
+func (d *DB) UpdateHeartbeats(heartbeats []ClientHeartbeat) ([]string, error) {
+	var recovered []string
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("heartbeats"))
+		if err != nil {
+			return err
+		}
+		for _, ch := range heartbeats {
+			if v := b.Get([]byte(ch.Name)); v != nil {
+				var prev ClientHeartbeat
+				if err := json.Unmarshal(v, &prev); err == nil {
+					ch.Inherit(prev)
+					if prev.Missing || prev.Failed {
+						recovered = append(recovered, ch.Name)
+					}
+				}
+			}
+			ch.Missing = false
+			data, err := json.Marshal(ch)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(ch.Name), data); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return recovered, nil
 }
 
 func (d *DB) GetAllHeartbeats() (map[string]ClientHeartbeat, error) {
@@ -84,6 +182,96 @@ func (d *DB) Get(name string) (ClientHeartbeat, bool) {
 }
 
 func (d *DB) SetMissing(name string, missing bool) error {
+	return d.modify(name, func(ch *ClientHeartbeat) {
+		ch.Missing = missing
+	})
+}
+
+// SetStarted records that the named client started a run at t.
+// Unknown clients are ignored, like in SetMissing.
+func (d *DB) SetStarted(name string, t time.Time) error {
+	return d.modify(name, func(ch *ClientHeartbeat) {
+		ch.StartedAt = &t
+	})
+}
+
+// SetMessage replaces the last message of the named client without changing its status.
+// Unknown clients are ignored, like in SetMissing.
+func (d *DB) SetMessage(name, message string) error {
+	return d.modify(name, func(ch *ClientHeartbeat) {
+		ch.Message = message
+	})
+}
+
+// SetExpiryNotified records the smallest expiry threshold in days notified for
+// the named client. Unknown clients are ignored, like in SetMissing.
+func (d *DB) SetExpiryNotified(name string, days int) error {
+	return d.modify(name, func(ch *ClientHeartbeat) {
+		ch.ExpiryNotified = days
+	})
+}
+
+// SetAcknowledged records that the named client's current alert was
+// acknowledged by someone at t. Unknown clients are ignored, like in SetMissing.
+func (d *DB) SetAcknowledged(name, by string, t time.Time) error {
+	return d.modify(name, func(ch *ClientHeartbeat) {
+		ch.AcknowledgedAt = &t
+		ch.AcknowledgedBy = by
+	})
+}
+
+// SetConnected records that the named connection-based client connected or
+// disconnected at t. A disconnect also counts as the last time the client was seen.
+// Unknown clients are ignored, like in SetMissing.
+func (d *DB) SetConnected(name string, connected bool, t time.Time) error {
+	return d.modify(name, func(ch *ClientHeartbeat) {
+		ch.Connection = true
+		if connected {
+			ch.ConnectedSince = &t
+		} else {
+			ch.ConnectedSince = nil
+			ch.Timestamp = t
+		}
+	})
+}
+
+// ResetConnections marks all connected clients as disconnected at t. Connections
+// do not survive a restart, so this is called on startup.
+func (d *DB) ResetConnections(t time.Time) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("heartbeats"))
+		if b == nil {
+			return nil
+		}
+		updates := map[string][]byte{}
+		err := b.ForEach(func(k, v []byte) error {
+			var ch ClientHeartbeat
+			if err := json.Unmarshal(v, &ch); err != nil || ch.ConnectedSince == nil {
+				return nil
+			}
+			ch.ConnectedSince = nil
+			ch.Timestamp = t
+			data, err := json.Marshal(ch)
+			if err != nil {
+				return err
+			}
+			updates[string(k)] = data
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for k, v := range updates {
+			if err := b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// modify applies fn to the stored heartbeat of the named client, if it exists.
+func (d *DB) modify(name string, fn func(*ClientHeartbeat)) error {
 	return d.db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte("heartbeats"))
 		if b == nil {
@@ -97,7 +285,7 @@ func (d *DB) SetMissing(name string, missing bool) error {
 		if err := json.Unmarshal(v, &ch); err != nil {
 			return err
 		}
-		ch.Missing = missing
+		fn(&ch)
 		data, err := json.Marshal(ch)
 		if err != nil {
 			return err
@@ -106,10 +294,82 @@ func (d *DB) SetMissing(name string, missing bool) error {
 	})
 }
 
-// Delete removes a client heartbeat entry from the database.
-// Returns nil if the bucket does not exist or the key is absent.
+// AddLog stores a log entry for the named client and prunes all but the newest keep entries.
+func (d *DB) AddLog(name string, entry LogEntry, keep int) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		logs, err := tx.CreateBucketIfNotExists([]byte("logs"))
+		if err != nil {
+			return err
+		}
+		b, err := logs.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		if err := b.Put(key, data); err != nil {
+			return err
+		}
+		// Keys are sequence numbers, so the oldest entries come first
+		n := 0
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			n++
+		}
+		for k, _ := c.First(); k != nil && n > keep; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			n--
+		}
+		return nil
+	})
+}
+
+// Logs returns the stored log entries of the named client, newest first.
+func (d *DB) Logs(name string) ([]LogEntry, error) {
+	var entries []LogEntry
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		logs := tx.Bucket([]byte("logs"))
+		if logs == nil {
+			return nil
+		}
+		b := logs.Bucket([]byte(name))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var entry LogEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Delete removes a client heartbeat entry and its logs from the database.
+// Returns nil if the bucket does not exist or the key is absent. The token hash
+// is kept, so nobody else can register a device under the same name and obtain
+// a fresh token for it.
 func (d *DB) Delete(name string) error {
 	return d.db.Update(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket([]byte("logs")); b != nil {
+			if err := b.DeleteBucket([]byte(name)); err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+				return err
+			}
+		}
 		b := tx.Bucket([]byte("heartbeats"))
 		if b == nil {
 			return nil
@@ -118,6 +378,81 @@ func (d *DB) Delete(name string) error {
 	})
 }
 
+// hashToken returns the hex-encoded SHA-256 digest of a device token.
+// Only digests are persisted so a leaked database does not leak tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetToken stores the hash of token for the named device, replacing any previous token.
+func (d *DB) SetToken(name, token string) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("tokens"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(name), []byte(hashToken(token)))
+	})
+}
+
+// HasToken reports whether a token has been issued for the named device.
+func (d *DB) HasToken(name string) bool {
+	found := false
+	_ = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("tokens"))
+		if b == nil {
+			return nil
+		}
+		found = b.Get([]byte(name)) != nil
+		return nil
+	})
+	return found
+}
+
+// VerifyToken reports whether token matches the token issued for the named device.
+// Returns false if no token has been issued.
+func (d *DB) VerifyToken(name, token string) bool {
+	if token == "" {
+		return false
+	}
+	want := []byte(hashToken(token))
+	ok := false
+	_ = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("tokens"))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(name)); v != nil {
+			ok = subtle.ConstantTimeCompare(v, want) == 1
+		}
+		return nil
+	})
+	return ok
+}
+
+// LookupToken returns the name of the device the token was issued to.
+func (d *DB) LookupToken(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	want := []byte(hashToken(token))
+	var name string
+	_ = d.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("tokens"))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if subtle.ConstantTimeCompare(v, want) == 1 {
+				name = string(k)
+			}
+			return nil
+		})
+	})
+	return name, name != ""
+}
+
 func (d *DB) Close() error {
 	return d.db.Close()
 }
@@ -4,6 +4,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"go.etcd.io/bbolt"
 )
 
 func testDBPath(t *testing.T, name string) string {
@@ -49,6 +51,28 @@ func TestHeartbeatAndMissingState(t *testing.T) {
 	}
 }
 
+func TestInsertHeartbeat(t *testing.T) {
+	db, err := Open(testDBPath(t, "test_insert.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().Truncate(time.Second)
+	if err := db.InsertHeartbeat(ClientHeartbeat{Name: "client1", Timestamp: now}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if ch, ok := db.Get("client1"); !ok || !ch.Timestamp.Equal(now) {
+		t.Errorf("unexpected heartbeat: %+v", ch)
+	}
+	if err := db.InsertHeartbeat(ClientHeartbeat{Name: "client1", Timestamp: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("insert existing: %v", err)
+	}
+	if ch, _ := db.Get("client1"); !ch.Timestamp.Equal(now) {
+		t.Errorf("existing heartbeat overwritten: %+v", ch)
+	}
+}
+
 func TestMultipleHeartbeats(t *testing.T) {
 	db, err := Open(testDBPath(t, "test_multi.db"))
 	if err != nil {
@@ -203,3 +227,186 @@ func TestDelete(t *testing.T) {
 		t.Errorf("Delete nonexistent returned error: %v", err)
 	}
 }
+
+func TestTokens(t *testing.T) {
+	db, err := Open(testDBPath(t, "test_tokens.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	if db.HasToken("client1") {
+		t.Error("expected no token before SetToken")
+	}
+	if err := db.SetToken("client1", "secret-token"); err != nil {
+		t.Fatalf("set token: %v", err)
+	}
+	if !db.HasToken("client1") {
+		t.Error("expected token after SetToken")
+	}
+	if !db.VerifyToken("client1", "secret-token") {
+		t.Error("expected token to verify")
+	}
+	if db.VerifyToken("client1", "wrong") || db.VerifyToken("client1", "") {
+		t.Error("wrong or empty token must not verify")
+	}
+	if name, ok := db.LookupToken("secret-token"); !ok || name != "client1" {
+		t.Errorf("LookupToken = %q, %v; want client1, true", name, ok)
+	}
+
+	// Rotation invalidates the old token
+	if err := db.SetToken("client1", "rotated"); err != nil {
+		t.Fatalf("rotate token: %v", err)
+	}
+	if db.VerifyToken("client1", "secret-token") {
+		t.Error("old token still valid after rotation")
+	}
+	if _, ok := db.LookupToken("secret-token"); ok {
+		t.Error("old token still resolvable after rotation")
+	}
+
+	// Deleting the device keeps its token, so the name cannot be taken over
+	if err := db.Delete("client1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if !db.VerifyToken("client1", "rotated") {
+		t.Error("token should survive deleting the device")
+	}
+}
+
+func TestTokensStoredHashed(t *testing.T) {
+	db, err := Open(testDBPath(t, "test_tokens_hashed.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.SetToken("client1", "plain-token"); err != nil {
+		t.Fatalf("set token: %v", err)
+	}
+	var stored string
+	_ = db.db.View(func(tx *bbolt.Tx) error {
+		stored = string(tx.Bucket([]byte("tokens")).Get([]byte("client1")))
+		return nil
+	})
+	if stored == "plain-token" || stored != hashToken("plain-token") {
+		t.Errorf("token not stored as hash: %q", stored)
+	}
+}
+
+func TestUpdateHeartbeats(t *testing.T) {
+	db, err := Open(testDBPath(t, "test_batch.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().Truncate(time.Second)
+	if err := db.UpdateHeartbeat("missing", now.Add(-time.Hour), true); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := db.UpdateHeartbeat("present", now.Add(-time.Minute), false); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	recovered, err := db.UpdateHeartbeats([]ClientHeartbeat{
+		{Name: "missing", Timestamp: now},
+		{Name: "present", Timestamp: now, Payload: []byte(`{"temp":21.5}`)},
+		{Name: "new", Timestamp: now},
+	})
+	if err != nil {
+		t.Fatalf("batch update: %v", err)
+	}
+	if len(recovered) != 1 || recovered[0] != "missing" {
+		t.Errorf("expected only 'missing' to recover, got %v", recovered)
+	}
+
+	beats, _ := db.GetAllHeartbeats()
+	if len(beats) != 3 {
+		t.Fatalf("expected 3 heartbeats, got %d", len(beats))
+	}
+	for name, ch := range beats {
+		if ch.Missing || !ch.Timestamp.Equal(now) {
+			t.Errorf("%s not updated: %+v", name, ch)
+		}
+	}
+	if string(beats["present"].Payload) != `{"temp":21.5}` {
+		t.Errorf("payload not stored: %s", beats["present"].Payload)
+	}
+}
+
+func TestConnections(t *testing.T) {
+	db, err := Open(testDBPath(t, "test_connections.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().Truncate(time.Second)
+	if err := db.UpdateHeartbeat("agent", now.Add(-time.Hour), false); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := db.SetConnected("agent", true, now); err != nil {
+		t.Fatalf("set connected: %v", err)
+	}
+	ch, _ := db.Get("agent")
+	if !ch.Connection || ch.ConnectedSince == nil || !ch.ConnectedSince.Equal(now) {
+		t.Fatalf("expected connected agent, got %+v", ch)
+	}
+
+	later := now.Add(time.Minute)
+	if err := db.ResetConnections(later); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	ch, _ = db.Get("agent")
+	if !ch.Connection || ch.ConnectedSince != nil || !ch.Timestamp.Equal(later) {
+		t.Errorf("expected disconnected agent last seen at reset, got %+v", ch)
+	}
+
+	// A heartbeat from another transport while disconnected drops the connection
+	if _, err := db.UpdateHeartbeats([]ClientHeartbeat{{Name: "agent", Timestamp: later}}); err != nil {
+		t.Fatalf("update heartbeats: %v", err)
+	}
+	if ch, _ = db.Get("agent"); ch.Connection {
+		t.Errorf("expected push heartbeat to clear the connection, got %+v", ch)
+	}
+}
+
+func TestLogs(t *testing.T) {
+	db, err := Open(testDBPath(t, "test_logs.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	if entries, err := db.Logs("job"); err != nil || len(entries) != 0 {
+		t.Fatalf("expected no logs, got %v, %v", entries, err)
+	}
+	now := time.Now().Truncate(time.Second)
+	for i, out := range []string{"run 1", "run 2", "run 3", "run 4"} {
+		entry := LogEntry{Timestamp: now.Add(time.Duration(i) * time.Minute), Output: out, Failed: i == 3}
+		if err := db.AddLog("job", entry, 3); err != nil {
+			t.Fatalf("add log: %v", err)
+		}
+	}
+	entries, err := db.Logs("job")
+	if err != nil {
+		t.Fatalf("logs: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Output != "run 4" || entries[2].Output != "run 2" {
+		t.Fatalf("expected newest 3 logs newest first, got %+v", entries)
+	}
+	if !entries[0].Failed || entries[1].Failed {
+		t.Errorf("failed flag not stored: %+v", entries)
+	}
+
+	if err := db.UpdateHeartbeat("job", now, false); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := db.Delete("job"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if entries, _ := db.Logs("job"); len(entries) != 0 {
+		t.Errorf("logs should be removed with device, got %d", len(entries))
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-smtp"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// maxInboundEmail caps the size of accepted report mails.
+const maxInboundEmail = 1 << 20
+
+// newEmailServer returns an SMTP server that records a heartbeat for every mail
+// addressed to <device>@<domain> or <device>+<token>@<domain>.
+func newEmailServer(cfg *config.Config, notifiers []notify.Notifier) *smtp.Server {
+	be := smtp.BackendFunc(func(c *smtp.Conn) (smtp.Session, error) {
+		return &emailSession{cfg: cfg, notifiers: notifiers, remote: c.Conn().RemoteAddr().String()}, nil
+	})
+	s := smtp.NewServer(be)
+	s.Addr = cfg.InboundEmail.ListenAddr
+	s.Domain = cfg.InboundEmail.Domain
+	s.MaxMessageBytes = maxInboundEmail
+	s.MaxRecipients = 50
+	s.ReadTimeout = time.Minute
+	s.WriteTimeout = time.Minute
+	return s
+}
+
+// emailSession collects the device recipients of a single mail transaction.
+type emailSession struct {
+	cfg       *config.Config
+	notifiers []notify.Notifier
+	remote    string
+	devices   []string
+}
+
+func (s *emailSession) Reset() {
+	s.devices = nil
+}
+
+func (s *emailSession) Logout() error {
+	return nil
+}
+
+func (s *emailSession) Mail(from string, opts *smtp.MailOptions) error {
+	return nil
+}
+
+func (s *emailSession) Rcpt(to string, opts *smtp.RcptOptions) error {
+	name, token, err := parseDeviceAddress(to, s.cfg.InboundEmail.Domain)
+	if err != nil {
+		return &smtp.SMTPError{Code: 550, EnhancedCode: smtp.EnhancedCode{5, 1, 1}, Message: err.Error()}
+	}
+	if err := authorizeCompact(s.cfg, name, token, "", ""); err != nil {
+		log.Printf("Security event: rejected email heartbeat for %s from %s: %v", name, s.remote, err)
+		return &smtp.SMTPError{Code: 550, EnhancedCode: smtp.EnhancedCode{5, 7, 1}, Message: "Recipient not authorized"}
+	}
+	s.devices = append(s.devices, name)
+	return nil
+}
+
+func (s *emailSession) Data(r io.Reader) error {
+	subject := ""
+	if msg, err := mail.ReadMessage(r); err == nil {
+		subject = decodeSubject(msg.Header.Get("Subject"))
+		// Drain the body so the client sees a clean end of DATA
+		_, _ = io.Copy(io.Discard, msg.Body)
+	} else {
+		log.Printf("Unparsable email from %s: %v", s.remote, err)
+		_, _ = io.Copy(io.Discard, r)
+	}
+	st := heartbeatStatus{
+		Failed:  isFailureSubject(subject, s.cfg.InboundEmail.FailureKeywords),
+		Message: subject,
+	}
+	for _, name := range s.devices {
+		log.Printf("Received email heartbeat from client: %s (subject: %q)", name, subject)
+		recordStatus(s.cfg, s.notifiers, name, st)
+	}
+	return nil
+}
+
+// parseDeviceAddress extracts the device name and optional token from
+// <device>[+<token>]@<domain>. Addresses for other domains are rejected.
+func parseDeviceAddress(addr, domain string) (name, token string, err error) {
+	local, host, ok := strings.Cut(strings.Trim(addr, "<>"), "@")
+	if !ok || local == "" {
+		return "", "", errors.New("invalid recipient address")
+	}
+	if domain != "" && !strings.EqualFold(host, domain) {
+		return "", "", errors.New("unknown recipient domain")
+	}
+	name, token, _ = strings.Cut(local, "+")
+	if name == "" {
+		return "", "", errors.New("invalid recipient address")
+	}
+	return name, token, nil
+}
+
+// decodeSubject decodes RFC 2047 encoded words, falling back to the raw header.
+func decodeSubject(raw string) string {
+	dec := new(mime.WordDecoder)
+	if decoded, err := dec.DecodeHeader(raw); err == nil {
+		return decoded
+	}
+	return raw
+}
+
+// isFailureSubject reports whether subject contains one of keywords, ignoring case.
+func isFailureSubject(subject string, keywords []string) bool {
+	lower := strings.ToLower(subject)
+	for _, k := range keywords {
+		if k != "" && strings.Contains(lower, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
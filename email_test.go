@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net"
+	"net/smtp"
+	"path/filepath"
+	"testing"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+func TestParseDeviceAddress(t *testing.T) {
+	tests := []struct {
+		addr, name, token string
+		wantErr           bool
+	}{
+		{"nas@hb.example.com", "nas", "", false},
+		{"<nas@HB.example.com>", "nas", "", false},
+		{"ups+abc-123@hb.example.com", "ups", "abc-123", false},
+		{"nas@other.example.com", "", "", true},
+		{"@hb.example.com", "", "", true},
+		{"+token@hb.example.com", "", "", true},
+		{"no-at-sign", "", "", true},
+	}
+	for _, tt := range tests {
+		name, token, err := parseDeviceAddress(tt.addr, "hb.example.com")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDeviceAddress(%q) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+			continue
+		}
+		if name != tt.name || token != tt.token {
+			t.Errorf("parseDeviceAddress(%q) = %q, %q; want %q, %q", tt.addr, name, token, tt.name, tt.token)
+		}
+	}
+}
+
+func TestIsFailureSubject(t *testing.T) {
+	keywords := []string{"FAILED", "error"}
+	if !isFailureSubject("Backup job failed on nas01", keywords) {
+		t.Error("expected case-insensitive keyword match")
+	}
+	if !isFailureSubject("UPS ERROR: battery", keywords) {
+		t.Error("expected keyword match")
+	}
+	if isFailureSubject("Backup completed successfully", keywords) {
+		t.Error("unexpected failure classification")
+	}
+	if isFailureSubject("Backup failed", nil) {
+		t.Error("no keywords must never classify as failure")
+	}
+}
+
+func TestEmailServer(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-email.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{
+		TimeoutSeconds: 600,
+		InboundEmail: config.InboundEmail{
+			Domain:          "hb.example.com",
+			FailureKeywords: []string{"FAILED"},
+		},
+	}
+	rec := &recordingNotifier{}
+	srv := newEmailServer(cfg, []notify.Notifier{rec})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	defer srv.Close()
+
+	send := func(to, subject string) error {
+		msg := "From: nas@example.com\r\nTo: " + to + "\r\nSubject: " + subject + "\r\n\r\nreport body\r\n"
+		return smtp.SendMail(l.Addr().String(), nil, "nas@example.com", []string{to}, []byte(msg))
+	}
+
+	if err := send("nas@hb.example.com", "Backup OK"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	ch, ok := dbInstance.Get("nas")
+	if !ok || ch.Failed || ch.Message != "Backup OK" {
+		t.Errorf("unexpected heartbeat after success mail: %+v (found=%v)", ch, ok)
+	}
+
+	if err := send("nas@hb.example.com", "=?UTF-8?Q?Backup_FAILED_=E2=9C=97?="); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	ch, _ = dbInstance.Get("nas")
+	if !ch.Failed || ch.Message != "Backup FAILED ✗" {
+		t.Errorf("unexpected heartbeat after failure mail: %+v", ch)
+	}
+	if rec.count() != 1 {
+		t.Errorf("expected 1 failure notification, got %d", rec.count())
+	}
+
+	if err := send("nas@other.example.com", "Backup OK"); err == nil {
+		t.Error("expected mail to foreign domain to be rejected")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+)
+
+// Device event types published to watchers.
+const (
+	eventHeartbeat = "heartbeat"
+	eventTimeout   = "timeout"
+	eventRecovery  = "recovery"
+	eventFailure   = "failure"
+	eventDeleted   = "deleted"
+)
+
+// deviceEvent is a device state change. Device holds the state after the event.
+type deviceEvent struct {
+	Type   string
+	Device db.ClientHeartbeat
+	Time   time.Time
+}
+
+var (
+	eventSubscribers = make(map[chan deviceEvent]struct{})
+	eventMu          sync.Mutex
+)
+
+// subscribeEvents registers a new event watcher. The returned function
+// unregisters it and must be called once the watcher is done.
+func subscribeEvents() (<-chan deviceEvent, func()) {
+	ch := make(chan deviceEvent, 64)
+	eventMu.Lock()
+	eventSubscribers[ch] = struct{}{}
+	eventMu.Unlock()
+	return ch, func() {
+		eventMu.Lock()
+		delete(eventSubscribers, ch)
+		eventMu.Unlock()
+		close(ch)
+	}
+}
+
+// publishEvent delivers an event to all watchers. Slow watchers miss events
+// rather than blocking heartbeat processing.
+func publishEvent(eventType string, device db.ClientHeartbeat) {
+	ev := deviceEvent{Type: eventType, Device: device, Time: time.Now()}
+	eventMu.Lock()
+	for ch := range eventSubscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+	eventMu.Unlock()
+}
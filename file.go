@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+)
+
+// errNotModified is returned by file checks when the watched file did not change
+// since the last recorded heartbeat.
+var errNotModified = errors.New("not modified")
+
+// newFileProber returns a check that treats a new modification of the newest
+// file matching d.Path, with at least d.MinSize bytes, as a heartbeat. The
+// heartbeat carries the modification time, so a file that stopped changing times
+// out as if its job had stopped sending heartbeats, even across restarts.
+func newFileProber(d config.Device) (prober, error) {
+	if d.Path == "" {
+		return nil, errors.New("file watch needs a path")
+	}
+	if _, err := filepath.Match(d.Path, ""); err != nil {
+		return nil, fmt.Errorf("file watch path: %w", err)
+	}
+	return func(ctx context.Context) (heartbeatStatus, error) {
+		var st heartbeatStatus
+		modified, err := newestModification(d.Path, d.MinSize)
+		if err != nil {
+			return st, err
+		}
+		if ch, ok := dbInstance.Get(d.Name); ok && !modified.After(ch.Timestamp) {
+			return st, errNotModified
+		}
+		st.Timestamp = modified
+		return st, nil
+	}, nil
+}
+
+// newestModification returns the latest modification time of the regular files
+// matching pattern that have at least minSize bytes.
+func newestModification(pattern string, minSize int64) (time.Time, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var newest time.Time
+	found := false
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil || !info.Mode().IsRegular() || info.Size() < minSize {
+			continue
+		}
+		if !found || info.ModTime().After(newest) {
+			newest, found = info.ModTime(), true
+		}
+	}
+	if !found {
+		if len(matches) > 0 && minSize > 0 {
+			return time.Time{}, fmt.Errorf("no file matching %s with at least %d bytes", pattern, minSize)
+		}
+		return time.Time{}, fmt.Errorf("no file matching %s", pattern)
+	}
+	return newest, nil
+}
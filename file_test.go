@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+)
+
+func TestNewestModification(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	write := func(name string, size int, mtime time.Time) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("backup-1.tar", 100, now.Add(-2*time.Hour))
+	write("backup-2.tar", 100, now.Add(-time.Hour))
+	write("backup-3.tar", 1, now)
+	if err := os.Mkdir(filepath.Join(dir, "backup-dir.tar"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	pattern := filepath.Join(dir, "backup-*.tar")
+	if got, err := newestModification(pattern, 0); err != nil || !got.Equal(now) {
+		t.Errorf("expected newest file at %v, got %v, %v", now, got, err)
+	}
+	if got, err := newestModification(pattern, 10); err != nil || !got.Equal(now.Add(-time.Hour)) {
+		t.Errorf("expected small file to be skipped, got %v, %v", got, err)
+	}
+	if _, err := newestModification(pattern, 1000); err == nil || !strings.Contains(err.Error(), "1000 bytes") {
+		t.Errorf("expected size error, got %v", err)
+	}
+	if _, err := newestModification(filepath.Join(dir, "*.csv"), 0); err == nil {
+		t.Error("expected error without matching file")
+	}
+}
+
+func TestFileProber(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-file.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	if _, err := newProber(config.Device{Type: "file"}); err == nil {
+		t.Error("expected error without path")
+	}
+	if _, err := newProber(config.Device{Type: "file", Path: "[invalid"}); err == nil {
+		t.Error("expected error for invalid glob")
+	}
+
+	path := filepath.Join(t.TempDir(), "export.csv")
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.WriteFile(path, []byte("a,b\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	d := config.Device{Name: "export", Type: "file", Path: path}
+	check, err := newProber(d)
+	if err != nil {
+		t.Fatalf("newProber: %v", err)
+	}
+	cfg := &config.Config{TimeoutSeconds: 600}
+	ctx := context.Background()
+
+	st, err := check(ctx)
+	if err != nil || !st.Timestamp.Equal(mtime) {
+		t.Fatalf("expected heartbeat at modification time, got %+v, %v", st, err)
+	}
+	recordStatus(cfg, nil, d.Name, st)
+	if ch, _ := dbInstance.Get(d.Name); !ch.Timestamp.Equal(mtime) {
+		t.Errorf("expected last seen to be the modification time, got %v", ch.Timestamp)
+	}
+
+	if _, err := check(ctx); !errors.Is(err, errNotModified) {
+		t.Errorf("expected errNotModified for unchanged file, got %v", err)
+	}
+
+	later := mtime.Add(30 * time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := check(ctx); err != nil || !st.Timestamp.Equal(later) {
+		t.Errorf("expected new heartbeat after modification, got %+v, %v", st, err)
+	}
+}
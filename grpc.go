@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/api"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// grpcService implements api.HeartbeatServiceServer on top of the same DB and
+// notification logic as the HTTP handlers.
+type grpcService struct {
+	api.UnimplementedHeartbeatServiceServer
+	cfg       *config.Config
+	notifiers []notify.Notifier
+}
+
+func newGRPCServer(cfg *config.Config, notifiers []notify.Notifier) *grpc.Server {
+	s := grpc.NewServer()
+	api.RegisterHeartbeatServiceServer(s, &grpcService{cfg: cfg, notifiers: notifiers})
+	return s
+}
+
+func (s *grpcService) Heartbeat(ctx context.Context, req *api.HeartbeatRequest) (*api.HeartbeatResponse, error) {
+	name := req.GetName()
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing name")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := strings.TrimPrefix(firstMetadata(md, "authorization"), "Bearer ")
+	if err := authorizeCompact(s.cfg, name, token, firstMetadata(md, "x-timestamp"), firstMetadata(md, "x-signature")); err != nil {
+		log.Printf("Security event: rejected gRPC heartbeat for %s: %v", name, err)
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid credentials")
+	}
+	log.Printf("Received gRPC heartbeat from client: %s", name)
+	if !dbInstance.HasToken(name) {
+		// First contact: hand out a token the client can use from now on
+		if token, err := issueToken(name); err != nil {
+			log.Printf("Token error for %s: %v", name, err)
+		} else if err := grpc.SetHeader(ctx, metadata.Pairs("x-device-token", token)); err != nil {
+			log.Printf("gRPC header error: %v", err)
+		}
+	}
+	recordStatus(s.cfg, s.notifiers, name, heartbeatStatus{Failed: req.GetFailed(), Message: req.GetMessage()})
+	return &api.HeartbeatResponse{}, nil
+}
+
+// authorizeAdmin checks the admin token in the "authorization: Bearer" metadata
+// of ctx, if an admin token is configured.
+func (s *grpcService) authorizeAdmin(ctx context.Context, method string) error {
+	if s.cfg.AdminToken == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := strings.TrimPrefix(firstMetadata(md, "authorization"), "Bearer ")
+	if !isAdminToken(s.cfg, token) {
+		log.Printf("Security event: rejected gRPC %s: missing or invalid admin token", method)
+		return status.Error(codes.Unauthenticated, "missing or invalid admin token")
+	}
+	return nil
+}
+
+func (s *grpcService) ListDevices(ctx context.Context, req *api.ListDevicesRequest) (*api.ListDevicesResponse, error) {
+	if err := s.authorizeAdmin(ctx, "ListDevices"); err != nil {
+		return nil, err
+	}
+	heartbeats, err := dbInstance.GetAllHeartbeats()
+	if err != nil {
+		log.Printf("DB error: %v", err)
+		return nil, status.Error(codes.Internal, "DB error")
+	}
+	resp := &api.ListDevicesResponse{}
+	for _, ch := range heartbeats {
+		resp.Devices = append(resp.Devices, toProtoDevice(ch))
+	}
+	sort.Slice(resp.Devices, func(i, j int) bool {
+		return resp.Devices[i].GetName() < resp.Devices[j].GetName()
+	})
+	return resp, nil
+}
+
+func (s *grpcService) DeleteDevice(ctx context.Context, req *api.DeleteDeviceRequest) (*api.DeleteDeviceResponse, error) {
+	if err := s.authorizeAdmin(ctx, "DeleteDevice"); err != nil {
+		return nil, err
+	}
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing name")
+	}
+	if err := deleteDevice(s.cfg, req.GetName()); err != nil {
+		log.Printf("DB delete error for %s: %v", req.GetName(), err)
+		return nil, status.Error(codes.Internal, "DB error")
+	}
+	return &api.DeleteDeviceResponse{}, nil
+}
+
+func (s *grpcService) WatchEvents(req *api.WatchEventsRequest, stream grpc.ServerStreamingServer[api.Event]) error {
+	if err := s.authorizeAdmin(stream.Context(), "WatchEvents"); err != nil {
+		return err
+	}
+	events, unsubscribe := subscribeEvents()
+	defer unsubscribe()
+	for {
+		select {
+		case ev := <-events:
+			if err := stream.Send(toProtoEvent(ev)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func toProtoDevice(ch db.ClientHeartbeat) *api.Device {
+	d := &api.Device{
+		Name:    ch.Name,
+		Missing: ch.Missing,
+		Failed:  ch.Failed,
+		Message: ch.Message,
+	}
+	if !ch.Timestamp.IsZero() {
+		d.LastSeen = timestamppb.New(ch.Timestamp)
+	}
+	return d
+}
+
+var protoEventTypes = map[string]api.Event_Type{
+	eventHeartbeat: api.Event_TYPE_HEARTBEAT,
+	eventTimeout:   api.Event_TYPE_TIMEOUT,
+	eventRecovery:  api.Event_TYPE_RECOVERY,
+	eventFailure:   api.Event_TYPE_FAILURE,
+	eventDeleted:   api.Event_TYPE_DELETED,
+}
+
+func toProtoEvent(ev deviceEvent) *api.Event {
+	return &api.Event{
+		Type:   protoEventTypes[ev.Type],
+		Device: toProtoDevice(ev.Device),
+		Time:   timestamppb.New(ev.Time),
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/api"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+)
+
+func newTestGRPCClient(t *testing.T, cfg *config.Config) api.HeartbeatServiceClient {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	srv := newGRPCServer(cfg, nil)
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return api.NewHeartbeatServiceClient(conn)
+}
+
+func TestGRPCHeartbeatListDelete(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-grpc.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	client := newTestGRPCClient(t, &config.Config{TimeoutSeconds: 600})
+	ctx := context.Background()
+
+	var header metadata.MD
+	if _, err := client.Heartbeat(ctx, &api.HeartbeatRequest{Name: "svc-b"}, grpc.Header(&header)); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if len(header.Get("x-device-token")) != 1 {
+		t.Error("expected token for new device in response header")
+	}
+	if _, err := client.Heartbeat(ctx, &api.HeartbeatRequest{Name: "svc-a", Failed: true, Message: "exit 1"}); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if _, err := client.Heartbeat(ctx, &api.HeartbeatRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for empty name, got %v", err)
+	}
+
+	list, err := client.ListDevices(ctx, &api.ListDevicesRequest{})
+	if err != nil {
+		t.Fatalf("ListDevices: %v", err)
+	}
+	if len(list.GetDevices()) != 2 || list.GetDevices()[0].GetName() != "svc-a" {
+		t.Fatalf("unexpected device list: %v", list.GetDevices())
+	}
+	if d := list.GetDevices()[0]; !d.GetFailed() || d.GetMessage() != "exit 1" || d.GetLastSeen() == nil {
+		t.Errorf("unexpected device: %v", d)
+	}
+
+	if _, err := client.DeleteDevice(ctx, &api.DeleteDeviceRequest{Name: "svc-a"}); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+	if _, ok := dbInstance.Get("svc-a"); ok {
+		t.Error("svc-a should have been deleted")
+	}
+}
+
+func TestGRPCHeartbeatRequireToken(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-grpc-token.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+	_ = dbInstance.SetToken("svc", "good-token")
+
+	client := newTestGRPCClient(t, &config.Config{TimeoutSeconds: 600, RequireToken: true})
+	ctx := context.Background()
+
+	if _, err := client.Heartbeat(ctx, &api.HeartbeatRequest{Name: "svc"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without token, got %v", err)
+	}
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer good-token")
+	if _, err := client.Heartbeat(authCtx, &api.HeartbeatRequest{Name: "svc"}); err != nil {
+		t.Errorf("expected success with token, got %v", err)
+	}
+}
+
+func TestGRPCWatchEvents(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-grpc-watch.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	client := newTestGRPCClient(t, &config.Config{TimeoutSeconds: 600})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchEvents(ctx, &api.WatchEventsRequest{})
+	if err != nil {
+		t.Fatalf("WatchEvents: %v", err)
+	}
+	// The subscription is registered asynchronously; wait until it exists
+	for {
+		eventMu.Lock()
+		n := len(eventSubscribers)
+		eventMu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := client.Heartbeat(ctx, &api.HeartbeatRequest{Name: "svc", Failed: true}); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	want := []api.Event_Type{api.Event_TYPE_HEARTBEAT, api.Event_TYPE_FAILURE}
+	for _, w := range want {
+		ev, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if ev.GetType() != w || ev.GetDevice().GetName() != "svc" {
+			t.Errorf("got event %v for %q, want %v", ev.GetType(), ev.GetDevice().GetName(), w)
+		}
+	}
+}
+
+func TestGRPCAdminToken(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-grpc-admin.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+	if err := dbInstance.UpdateHeartbeat("svc", time.Now(), false); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	client := newTestGRPCClient(t, &config.Config{TimeoutSeconds: 600, AdminToken: "admin-secret"})
+	ctx := context.Background()
+	wrongCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong")
+	for _, c := range []context.Context{ctx, wrongCtx} {
+		if _, err := client.ListDevices(c, &api.ListDevicesRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("ListDevices: expected Unauthenticated, got %v", err)
+		}
+		if _, err := client.DeleteDevice(c, &api.DeleteDeviceRequest{Name: "svc"}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("DeleteDevice: expected Unauthenticated, got %v", err)
+		}
+		stream, err := client.WatchEvents(c, &api.WatchEventsRequest{})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("WatchEvents: expected Unauthenticated, got %v", err)
+		}
+	}
+	if _, ok := dbInstance.Get("svc"); !ok {
+		t.Fatal("unauthenticated DeleteDevice must not delete the device")
+	}
+
+	adminCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer admin-secret")
+	if list, err := client.ListDevices(adminCtx, &api.ListDevicesRequest{}); err != nil || len(list.GetDevices()) != 1 {
+		t.Errorf("ListDevices with admin token: %v, %v", list.GetDevices(), err)
+	}
+	if _, err := client.DeleteDevice(adminCtx, &api.DeleteDeviceRequest{Name: "svc"}); err != nil {
+		t.Errorf("DeleteDevice with admin token: %v", err)
+	}
+}
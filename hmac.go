@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+)
+
+// Headers carrying the HMAC signature of a heartbeat request.
+const (
+	signatureHeader = "X-Signature"
+	timestampHeader = "X-Timestamp"
+)
+
+var (
+	errUnsigned          = errors.New("missing signature or timestamp")
+	errBadTimestamp      = errors.New("invalid timestamp")
+	errStaleTimestamp    = errors.New("timestamp outside replay window")
+	errBadSignature      = errors.New("signature mismatch")
+	errReplayedSignature = errors.New("signature already used")
+)
+
+// replayCache remembers accepted signatures until they fall out of the replay window,
+// so a captured request cannot be resent while its timestamp is still valid.
+type replayCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+var usedSignatures = &replayCache{seen: make(map[string]time.Time)}
+
+// add records sig as used until expires and reports whether it was unused before.
+func (c *replayCache) add(sig string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for s, exp := range c.seen {
+		if now.After(exp) {
+			delete(c.seen, s)
+		}
+	}
+	if _, ok := c.seen[sig]; ok {
+		return false
+	}
+	c.seen[sig] = expires
+	return true
+}
+
+// computeSignature returns the hex-encoded HMAC-SHA256 of "<timestamp>.<body>".
+func computeSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks signature against body and timestamp (unix seconds).
+// The timestamp must lie within window of now and each signature is accepted once.
+func verifySignature(secret string, body []byte, timestamp, signature string, window time.Duration, now time.Time) error {
+	signature = strings.TrimPrefix(signature, "sha256=")
+	if timestamp == "" || signature == "" {
+		return errUnsigned
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errBadTimestamp
+	}
+	ts := time.Unix(unix, 0)
+	if ts.Before(now.Add(-window)) || ts.After(now.Add(window)) {
+		return errStaleTimestamp
+	}
+	want := computeSignature(secret, timestamp, body)
+	if !hmac.Equal([]byte(want), []byte(strings.ToLower(signature))) {
+		return errBadSignature
+	}
+	if !usedSignatures.add(want, ts.Add(window), now) {
+		return errReplayedSignature
+	}
+	return nil
+}
+
+// checkRequestSignature verifies the signature headers of r if the named device
+// has an HMAC secret configured. Devices without a secret are always accepted.
+func checkRequestSignature(cfg *config.Config, name string, r *http.Request, body []byte) error {
+	device, ok := cfg.Device(name)
+	if !ok || device.HMACSecret == "" {
+		return nil
+	}
+	if err := verifySignature(device.HMACSecret, body, r.Header.Get(timestampHeader), r.Header.Get(signatureHeader), cfg.ReplayWindow(), time.Now()); err != nil {
+		return fmt.Errorf("hmac: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+)
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Now()
+	window := 5 * time.Minute
+	body := []byte(`{"name":"sensor"}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		want      error
+	}{
+		{"unsigned", "", "", errUnsigned},
+		{"bad timestamp", "yesterday", computeSignature("s3cret", "yesterday", body), errBadTimestamp},
+		{"stale timestamp", stale, computeSignature("s3cret", stale, body), errStaleTimestamp},
+		{"wrong secret", ts, computeSignature("other", ts, body), errBadSignature},
+		{"valid", ts, "sha256=" + computeSignature("s3cret", ts, body), nil},
+		{"replayed", ts, computeSignature("s3cret", ts, body), errReplayedSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature("s3cret", body, tt.timestamp, tt.signature, window, now)
+			if err != tt.want {
+				t.Errorf("verifySignature() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayCacheExpires(t *testing.T) {
+	c := &replayCache{seen: make(map[string]time.Time)}
+	now := time.Now()
+	if !c.add("sig", now.Add(time.Minute), now) {
+		t.Fatal("first add should succeed")
+	}
+	if c.add("sig", now.Add(time.Minute), now) {
+		t.Error("second add within window should fail")
+	}
+	if !c.add("sig", now.Add(3*time.Minute), now.Add(2*time.Minute)) {
+		t.Error("add after expiry should succeed")
+	}
+}
+
+func TestHeartbeatEndpointSigned(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-hmac.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{
+		TimeoutSeconds:          600,
+		HMACReplayWindowSeconds: 300,
+		Devices:                 []config.Device{{Name: "signed", HMACSecret: "s3cret"}},
+	}
+	ts := httptest.NewServer(newMux(cfg, nil, ""))
+	defer ts.Close()
+
+	post := func(name string, sign func(ts string, body []byte) string) int {
+		t.Helper()
+		body := `{"name":"` + name + `"}`
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/heartbeat", strings.NewReader(body))
+		if sign != nil {
+			stamp := strconv.FormatInt(time.Now().Unix(), 10)
+			req.Header.Set(timestampHeader, stamp)
+			req.Header.Set(signatureHeader, sign(stamp, []byte(body)))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := post("signed", nil); got != http.StatusUnauthorized {
+		t.Errorf("unsigned: expected 401, got %d", got)
+	}
+	if got := post("signed", func(ts string, b []byte) string { return computeSignature("wrong", ts, b) }); got != http.StatusUnauthorized {
+		t.Errorf("bad signature: expected 401, got %d", got)
+	}
+	if _, ok := dbInstance.Get("signed"); ok {
+		t.Error("rejected heartbeat must not be stored")
+	}
+	if got := post("signed", func(ts string, b []byte) string { return "sha256=" + computeSignature("s3cret", ts, b) }); got != http.StatusOK {
+		t.Errorf("valid signature: expected 200, got %d", got)
+	}
+	// Devices without a secret are unaffected
+	if got := post("unsigned", nil); got != http.StatusOK {
+		t.Errorf("device without secret: expected 200, got %d", got)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// inboundWebhookHandler serves POST /webhooks/{path} for the configured inbound
+// webhooks, turning any JSON-sending service into a heartbeat source.
+func inboundWebhookHandler(cfg *config.Config, notifiers []notify.Notifier, basePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		hook, ok := cfg.InboundWebhook(strings.TrimPrefix(r.URL.Path, basePath+"/webhooks/"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("Not found"))
+			return
+		}
+		var payload any
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHeartbeatBody)).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if _, err := w.Write([]byte("Invalid JSON payload")); err != nil {
+				log.Printf("Write error: %v", err)
+			}
+			return
+		}
+		name, st, ignored, err := mapWebhookPayload(hook, payload)
+		if err != nil {
+			log.Printf("Inbound webhook %s: %v", hook.Path, err)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			if _, err := w.Write([]byte(err.Error())); err != nil {
+				log.Printf("Write error: %v", err)
+			}
+			return
+		}
+		if ignored {
+			log.Printf("Inbound webhook %s: ignoring event for %s", hook.Path, name)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		token := bearerToken(r)
+		if token == "" {
+			// Many webhook senders cannot set headers, only the URL
+			token = r.URL.Query().Get("token")
+		}
+		if err := authorizeCompact(cfg, name, token, "", ""); err != nil {
+			log.Printf("Security event: rejected webhook heartbeat for %s from %s: %v", name, r.RemoteAddr, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			if _, err := w.Write([]byte("Missing or invalid device token")); err != nil {
+				log.Printf("Write error: %v", err)
+			}
+			return
+		}
+		log.Printf("Received webhook heartbeat from client: %s (failed: %t)", name, st.Failed)
+		recordStatus(cfg, notifiers, name, st)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte("OK")); err != nil {
+			log.Printf("Write error: %v", err)
+		}
+	}
+}
+
+// mapWebhookPayload extracts the device name and status from payload as configured
+// by hook. ignored is true if the status is one of hook.IgnoreValues.
+func mapWebhookPayload(hook config.InboundWebhook, payload any) (name string, st heartbeatStatus, ignored bool, err error) {
+	name = hook.Name
+	if hook.NameField != "" {
+		v, ok := lookupField(payload, hook.NameField)
+		if !ok || fieldString(v) == "" {
+			return "", st, false, fmt.Errorf("no device name at %q", hook.NameField)
+		}
+		name = fieldString(v)
+	}
+	if name == "" {
+		return "", st, false, errors.New("no device name configured")
+	}
+	if hook.MessageField != "" {
+		if v, ok := lookupField(payload, hook.MessageField); ok {
+			st.Message = pingMessage([]byte(fieldString(v)))
+		}
+	}
+	if hook.StatusField == "" {
+		return name, st, false, nil
+	}
+	v, ok := lookupField(payload, hook.StatusField)
+	if !ok {
+		return "", st, false, fmt.Errorf("no status at %q", hook.StatusField)
+	}
+	status := fieldString(v)
+	switch {
+	case containsFold(hook.IgnoreValues, status):
+		ignored = true
+	case containsFold(hook.FailureValues, status):
+		st.Failed = true
+	case len(hook.SuccessValues) > 0 && !containsFold(hook.SuccessValues, status):
+		st.Failed = true
+	}
+	if st.Failed && st.Message == "" {
+		st.Message = "status: " + status
+	}
+	return name, st, ignored, nil
+}
+
+// lookupField resolves a dot-path expression like "$.build.steps[0].status" or
+// "build.steps.0.status" in a decoded JSON value.
+func lookupField(v any, expr string) (any, bool) {
+	expr = strings.TrimPrefix(strings.TrimPrefix(expr, "$"), ".")
+	expr = strings.ReplaceAll(strings.ReplaceAll(expr, "[", "."), "]", "")
+	if expr == "" {
+		return v, true
+	}
+	for _, key := range strings.Split(expr, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// fieldString formats a decoded JSON scalar; objects and arrays yield "".
+func fieldString(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return ""
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+func TestLookupField(t *testing.T) {
+	var payload any
+	if err := json.Unmarshal([]byte(`{"build":{"ok":true,"n":42,"steps":[{"name":"test"}]}}`), &payload); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr, want string
+		ok         bool
+	}{
+		{"build.ok", "true", true},
+		{"$.build.n", "42", true},
+		{"build.steps[0].name", "test", true},
+		{"build.steps.0.name", "test", true},
+		{"build.steps[1].name", "", false},
+		{"build.missing", "", false},
+		{"build.ok.deeper", "", false},
+	}
+	for _, tt := range tests {
+		v, ok := lookupField(payload, tt.expr)
+		if ok != tt.ok || fieldString(v) != tt.want {
+			t.Errorf("lookupField(%q) = %q, %v; want %q, %v", tt.expr, fieldString(v), ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMapWebhookPayload(t *testing.T) {
+	hook := config.InboundWebhook{
+		NameField:     "repo",
+		StatusField:   "result",
+		SuccessValues: []string{"success"},
+		IgnoreValues:  []string{"running"},
+		MessageField:  "title",
+	}
+	tests := []struct {
+		payload        map[string]any
+		failed, ignore bool
+		message        string
+	}{
+		{map[string]any{"repo": "app", "result": "SUCCESS", "title": "deploy"}, false, false, "deploy"},
+		{map[string]any{"repo": "app", "result": "failure"}, true, false, "status: failure"},
+		{map[string]any{"repo": "app", "result": "running"}, false, true, ""},
+	}
+	for _, tt := range tests {
+		name, st, ignored, err := mapWebhookPayload(hook, tt.payload)
+		if err != nil || name != "app" || st.Failed != tt.failed || ignored != tt.ignore || st.Message != tt.message {
+			t.Errorf("%v: got %q %+v ignored=%v err=%v", tt.payload, name, st, ignored, err)
+		}
+	}
+	if _, _, _, err := mapWebhookPayload(hook, map[string]any{"result": "success"}); err == nil {
+		t.Error("expected error for missing device name")
+	}
+}
+
+func TestInboundWebhookHandler(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-webhooks.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{
+		TimeoutSeconds: 600,
+		InboundWebhooks: []config.InboundWebhook{{
+			Path:          "ci",
+			Name:          "nightly",
+			StatusField:   "status",
+			FailureValues: []string{"failed"},
+		}},
+	}
+	rec := &recordingNotifier{}
+	ts := httptest.NewServer(newMux(cfg, []notify.Notifier{rec}, ""))
+	defer ts.Close()
+
+	post := func(path, body string) int {
+		t.Helper()
+		resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := post("/webhooks/unknown", `{}`); got != http.StatusNotFound {
+		t.Errorf("unknown path: expected 404, got %d", got)
+	}
+	if got := post("/webhooks/ci", `not json`); got != http.StatusBadRequest {
+		t.Errorf("invalid JSON: expected 400, got %d", got)
+	}
+	if got := post("/webhooks/ci", `{"status":"ok"}`); got != http.StatusOK {
+		t.Fatalf("success: expected 200, got %d", got)
+	}
+	if ch, ok := dbInstance.Get("nightly"); !ok || ch.Failed {
+		t.Errorf("expected successful heartbeat for nightly, got %+v, %v", ch, ok)
+	}
+	if got := post("/webhooks/ci", `{"status":"failed"}`); got != http.StatusOK {
+		t.Fatalf("failure: expected 200, got %d", got)
+	}
+	if ch, _ := dbInstance.Get("nightly"); !ch.Failed {
+		t.Error("expected nightly to be failed")
+	}
+	if rec.count() != 1 {
+		t.Errorf("expected one failure notification, got %d", rec.count())
+	}
+
+	// With require_token the token may be passed as query parameter
+	cfg.RequireToken = true
+	if err := dbInstance.SetToken("nightly", "hook-token"); err != nil {
+		t.Fatalf("set token: %v", err)
+	}
+	if got := post("/webhooks/ci", `{"status":"ok"}`); got != http.StatusUnauthorized {
+		t.Errorf("missing token: expected 401, got %d", got)
+	}
+	if got := post("/webhooks/ci?token=hook-token", `{"status":"ok"}`); got != http.StatusOK {
+		t.Errorf("query token: expected 200, got %d", got)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+)
+
+// maxLogSize caps a stored job log. Longer output keeps its end, where errors usually are.
+const maxLogSize = 100 << 10
+
+// Failure notifications include at most this many trailing lines and bytes of the log.
+const (
+	maxExcerptLines = 10
+	maxExcerptBytes = 1000
+)
+
+// truncateLog converts raw job output to valid UTF-8 of at most maxLogSize bytes,
+// dropping the beginning of longer output.
+func truncateLog(raw []byte) string {
+	s := strings.ToValidUTF8(string(raw), "\uFFFD")
+	if strings.TrimSpace(s) == "" {
+		return ""
+	}
+	if len(s) <= maxLogSize {
+		return s
+	}
+	return "[truncated]\n" + tailBytes(s, maxLogSize)
+}
+
+// logExcerpt returns the last lines of output for use in notifications.
+func logExcerpt(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > maxExcerptLines {
+		lines = lines[len(lines)-maxExcerptLines:]
+	}
+	excerpt := strings.Join(lines, "\n")
+	if len(excerpt) > maxExcerptBytes {
+		excerpt = "…" + tailBytes(excerpt, maxExcerptBytes)
+	}
+	return excerpt
+}
+
+// tailBytes returns the last n bytes of s, moved forward to a rune boundary.
+func tailBytes(s string, n int) string {
+	s = s[len(s)-n:]
+	for len(s) > 0 && !utf8.RuneStart(s[0]) {
+		s = s[1:]
+	}
+	return s
+}
+
+// storeLog adds entry to the log history of the named device. A log_retention
+// of 0 disables log storage.
+func storeLog(cfg *config.Config, name string, entry db.LogEntry) {
+	if cfg.LogRetention <= 0 {
+		return
+	}
+	if err := dbInstance.AddLog(name, entry, cfg.LogRetention); err != nil {
+		log.Printf("Log store error for %s: %v", name, err)
+	}
+}
+
+// deviceDetailHandler serves GET /web/device/{name}, showing the device state
+// and its stored job logs, newest first.
+func deviceDetailHandler(cfg *config.Config, basePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, basePath+"/web/device/")
+		ch, ok := dbInstance.Get(name)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if _, err := w.Write([]byte("Unknown device")); err != nil {
+				log.Printf("Write error: %v", err)
+			}
+			return
+		}
+		entries, err := dbInstance.Logs(name)
+		if err != nil {
+			log.Printf("Log read error for %s: %v", name, err)
+		}
+		escapedName := html.EscapeString(name)
+
+		var b strings.Builder
+		b.WriteString("<!DOCTYPE html>\n<html lang='en'>\n<head>\n<meta charset='UTF-8'>\n")
+		b.WriteString("<base href='" + html.EscapeString(basePath) + "/web/'>\n")
+		b.WriteString("<title>Dead Man's Switch - " + escapedName + "</title>\n")
+		b.WriteString("<meta name='viewport' content='width=device-width, initial-scale=1'>\n")
+		b.WriteString("<link rel='icon' type='image/png' href='favicon.png'>\n")
+		b.WriteString("<link rel='stylesheet' href='vendor/water.min.css'>\n</head>\n<body>\n")
+		b.WriteString("<p><a href='" + html.EscapeString(basePath) + "/web'>&larr; All devices</a></p>\n")
+		b.WriteString("<h1>" + escapedName + "</h1>\n<table><tbody>\n")
+
+		status := "ok"
+		switch {
+		case ch.Missing:
+			status = "missing"
+		case ch.Failed:
+			status = "failed"
+		}
+		rows := [][2]string{
+			{"Last Seen", ch.Timestamp.UTC().Format(time.RFC3339)},
+			{"Status", status},
+		}
+		if ch.ExpiresAt != nil {
+			rows = append(rows, [2]string{"Certificate Expires", ch.ExpiresAt.UTC().Format(time.RFC3339) + " (" + formatExpiry(*ch.ExpiresAt, time.Now()) + ")"})
+		}
+		if ch.ConnectedSince != nil {
+			rows = append(rows, [2]string{"Connected Since", ch.ConnectedSince.UTC().Format(time.RFC3339)})
+		}
+		if ch.StartedAt != nil {
+			rows = append(rows, [2]string{"Started", ch.StartedAt.UTC().Format(time.RFC3339)})
+		}
+		if ch.Message != "" {
+			rows = append(rows, [2]string{"Message", ch.Message})
+		}
+		for _, row := range rows {
+			b.WriteString("<tr><th>" + row[0] + "</th><td>" + html.EscapeString(row[1]) + "</td></tr>\n")
+		}
+		b.WriteString("</tbody></table>\n<h2>Logs</h2>\n")
+
+		if len(entries) == 0 {
+			b.WriteString("<p>No logs stored for this device.</p>\n")
+		}
+		for i, entry := range entries {
+			b.WriteString("<details")
+			if i == 0 {
+				b.WriteString(" open")
+			}
+			b.WriteString("><summary>")
+			b.WriteString(entry.Timestamp.UTC().Format(time.RFC3339))
+			if entry.Failed {
+				b.WriteString(" &mdash; failed")
+			}
+			b.WriteString("</summary><pre>")
+			b.WriteString(html.EscapeString(entry.Output))
+			b.WriteString("</pre></details>\n")
+		}
+		b.WriteString("</body>\n</html>\n")
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if _, err := w.Write([]byte(b.String())); err != nil {
+			log.Printf("Write error: %v", err)
+		}
+	}
+}
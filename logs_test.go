@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+func TestTruncateLog(t *testing.T) {
+	if got := truncateLog([]byte(" \n ")); got != "" {
+		t.Errorf("blank log should be dropped, got %q", got)
+	}
+	if got := truncateLog([]byte("ok\xff")); got != "ok�" {
+		t.Errorf("invalid UTF-8 not replaced: %q", got)
+	}
+	long := strings.Repeat("ä", maxLogSize) + "END"
+	got := truncateLog([]byte(long))
+	if !strings.HasPrefix(got, "[truncated]\n") || !strings.HasSuffix(got, "END") {
+		t.Errorf("expected truncated log keeping the end, got prefix %q", got[:20])
+	}
+	if len(got) > maxLogSize+len("[truncated]\n") || !utf8.ValidString(got) {
+		t.Errorf("truncated log too long or invalid: %d bytes", len(got))
+	}
+}
+
+func TestLogExcerpt(t *testing.T) {
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, "line "+strings.Repeat("x", i))
+	}
+	got := logExcerpt(strings.Join(lines, "\n") + "\n")
+	if strings.Count(got, "\n") != maxExcerptLines-1 || !strings.HasSuffix(got, lines[19]) {
+		t.Errorf("expected the last %d lines, got %q", maxExcerptLines, got)
+	}
+	if got := logExcerpt(strings.Repeat("y", 5000)); len(got) > maxExcerptBytes+len("…") {
+		t.Errorf("excerpt not capped: %d bytes", len(got))
+	}
+}
+
+func TestHeartbeatLogs(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-logs.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{TimeoutSeconds: 600, LogRetention: 2}
+	rec := &recordingNotifier{}
+	ts := httptest.NewServer(newMux(cfg, []notify.Notifier{rec}, ""))
+	defer ts.Close()
+
+	post := func(failed bool, output string) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]any{"name": "backup", "failed": failed, "log": output})
+		resp, err := http.Post(ts.URL+"/heartbeat", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+	}
+	post(false, "run 1 ok")
+	post(false, "run 2 ok")
+	post(true, "copying files\nrsync: <disk full>\n")
+
+	entries, err := dbInstance.Logs("backup")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected 2 retained logs, got %d, %v", len(entries), err)
+	}
+	if !entries[0].Failed || entries[1].Output != "run 2 ok" {
+		t.Errorf("unexpected logs: %+v", entries)
+	}
+	if rec.count() != 1 || !strings.Contains(rec.messages[0], "rsync: <disk full>") {
+		t.Errorf("expected failure notification with log excerpt, got %v", rec.messages)
+	}
+
+	resp, err := http.Get(ts.URL + "/web/device/backup")
+	if err != nil {
+		t.Fatalf("GET detail failed: %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	for _, want := range []string{"<h1>backup</h1>", "rsync: &lt;disk full&gt;", "run 2 ok", "failed"} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("detail page missing %q", want)
+		}
+	}
+	if strings.Contains(string(page), "run 1 ok") {
+		t.Error("pruned log still shown")
+	}
+
+	resp, err = http.Get(ts.URL + "/web/device/unknown")
+	if err != nil {
+		t.Fatalf("GET unknown failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown device, got %d", resp.StatusCode)
+	}
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/json"
+	"expvar"
 	"fmt"
 	"html"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"sort"
@@ -36,6 +43,9 @@ const (
 	defaultIndexHTML  = "web/index.html"
 )
 
+// maxHeartbeatBody caps the size of heartbeat request bodies.
+const maxHeartbeatBody = 1 << 20
+
 func monitor(cfg *config.Config, notifiers []notify.Notifier) {
 	// Wait until the next minute boundary (0 seconds)
 	now := time.Now()
@@ -45,33 +55,52 @@ func monitor(cfg *config.Config, notifiers []notify.Notifier) {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 	for range ticker.C {
-		heartbeats, err := dbInstance.GetAllHeartbeats()
-		if err != nil {
-			log.Printf("DB error: %v", err)
-			continue
-		}
-		for name, ch := range heartbeats {
-			missed := time.Since(ch.Timestamp) > cfg.Timeout()
-			duration := time.Since(ch.Timestamp).Round(time.Second)
-			durStr := formatDuration(duration)
-			if missed && !ch.Missing {
-				msg := cfg.NotificationMessages.Timeout
-				if msg == "" {
-					msg = "No heartbeat received in time from client: {{name}}. Last update was {{duration}} ago at {{timestamp}}."
-				}
-				msg = strings.ReplaceAll(msg, "{{name}}", name)
-				msg = strings.ReplaceAll(msg, "{{duration}}", durStr)
-				msg = strings.ReplaceAll(msg, "{{timestamp}}", ch.Timestamp.Format(time.RFC3339))
-				for _, n := range notifiers {
-					if err := n.Notify("Dead Man's Switch Triggered", msg); err != nil {
-						log.Printf("Notify error: %v", err)
-					}
-				}
-				if err := dbInstance.SetMissing(name, true); err != nil {
-					log.Printf("SetMissing error: %v", err)
-				}
-				broadcastDeviceTable(cfg) // update SSE clients on timeout
+		checkTimeouts(cfg, notifiers)
+	}
+}
+
+// checkTimeouts marks clients missing whose last heartbeat is older than the
+// timeout and sends the timeout notification for them.
+func checkTimeouts(cfg *config.Config, notifiers []notify.Notifier) {
+	heartbeats, err := dbInstance.GetAllHeartbeats()
+	if err != nil {
+		log.Printf("DB error: %v", err)
+		return
+	}
+	for name, ch := range heartbeats {
+		timeout := cfg.Timeout()
+		if ch.Connection {
+			if ch.ConnectedSince != nil {
+				continue
+			}
+			// Disconnected agents only get the grace period to reconnect
+			timeout = cfg.WebSocketGrace()
+		}
+		missed := time.Since(ch.Timestamp) > timeout
+		duration := time.Since(ch.Timestamp).Round(time.Second)
+		durStr := formatDuration(duration)
+		if missed && !ch.Missing {
+			msg := cfg.NotificationMessages.Timeout
+			if msg == "" {
+				msg = "No heartbeat received in time from client: {{name}}. Last update was {{duration}} ago at {{timestamp}}."
+			}
+			msg = strings.ReplaceAll(msg, "{{name}}", name)
+			msg = strings.ReplaceAll(msg, "{{duration}}", durStr)
+			msg = strings.ReplaceAll(msg, "{{timestamp}}", ch.Timestamp.Format(time.RFC3339))
+			sendNotification(notifiers, notify.Event{
+				Type:     notify.EventTimeout,
+				Subject:  "Dead Man's Switch Triggered",
+				Message:  msg,
+				Device:   name,
+				Status:   "missing",
+				LastSeen: ch.Timestamp,
+			})
+			if err := dbInstance.SetMissing(name, true); err != nil {
+				log.Printf("SetMissing error: %v", err)
 			}
+			ch.Missing = true
+			publishEvent(eventTimeout, ch)
+			broadcastDeviceTable(cfg) // update SSE clients on timeout
 		}
 	}
 }
@@ -87,6 +116,168 @@ func setupNotifiers(cfg *config.Config) []notify.Notifier {
 	return result
 }
 
+// heartbeatStatus is the outcome a client may report along with a heartbeat.
+// Log is the optional job output, kept in the device's log history. ExpiresAt
+// is set by certificate checks. A non-zero Timestamp replaces the time of
+// receipt, e.g. with the modification time of a watched file.
+type heartbeatStatus struct {
+	Failed    bool
+	Message   string
+	Log       string
+	ExpiresAt *time.Time
+	Timestamp time.Time
+}
+
+// recordHeartbeat stores a successful heartbeat for the named client.
+func recordHeartbeat(cfg *config.Config, notifiers []notify.Notifier, name string) {
+	recordStatus(cfg, notifiers, name, heartbeatStatus{})
+}
+
+// recordStatus stores a heartbeat with the given status for the named client and
+// refreshes SSE clients. A newly reported failure triggers a failure notification;
+// a success after a failure or timeout triggers a recovery notification.
+func recordStatus(cfg *config.Config, notifiers []notify.Notifier, name string, st heartbeatStatus) {
+	now := time.Now()
+	if !st.Timestamp.IsZero() {
+		now = st.Timestamp
+	}
+	// Check the previous state before updating
+	prev, known := dbInstance.Get(name)
+	ch := db.ClientHeartbeat{Name: name, Timestamp: now, Failed: st.Failed, Message: st.Message}
+	if known {
+		ch.Inherit(prev)
+	}
+	if st.ExpiresAt != nil {
+		if ch.ExpiresAt == nil || !ch.ExpiresAt.Equal(*st.ExpiresAt) {
+			// A renewed certificate starts over with its thresholds
+			ch.ExpiryNotified = 0
+		}
+		ch.ExpiresAt = st.ExpiresAt
+	}
+	if err := dbInstance.PutHeartbeat(ch); err != nil {
+		log.Printf("DB update error for %s: %v", name, err)
+	} else {
+		log.Printf("Stored to DB: {name: %s, timestamp: %s, failed: %t}", name, now.Format(time.RFC3339), st.Failed)
+		if st.Log != "" {
+			storeLog(cfg, name, db.LogEntry{Timestamp: now, Failed: st.Failed, Output: st.Log})
+		}
+		publishEvent(eventHeartbeat, ch)
+		broadcastDeviceTable(cfg)
+	}
+	switch {
+	case st.Failed && !(known && prev.Failed):
+		publishEvent(eventFailure, ch)
+		notifyFailure(cfg, notifiers, name, st.Message, st.Log)
+	case !st.Failed && known && (prev.Missing || prev.Failed):
+		publishEvent(eventRecovery, ch)
+		notifyRecovery(cfg, notifiers, name)
+	}
+}
+
+// deleteDevice removes the named device, but not its token, and refreshes SSE clients.
+func deleteDevice(cfg *config.Config, name string) error {
+	if err := dbInstance.Delete(name); err != nil {
+		return err
+	}
+	publishEvent(eventDeleted, db.ClientHeartbeat{Name: name})
+	// Notify SSE clients of the updated table
+	broadcastDeviceTable(cfg)
+	return nil
+}
+
+func notifyRecovery(cfg *config.Config, notifiers []notify.Notifier, name string) {
+	msg := cfg.NotificationMessages.Recovery
+	if msg == "" {
+		msg = "Heartbeat received again from client: {{name}}"
+	}
+	msg = strings.ReplaceAll(msg, "{{name}}", name)
+	sendNotification(notifiers, notify.Event{
+		Type:     notify.EventRecovery,
+		Subject:  "Dead Man's Switch Recovery",
+		Message:  msg,
+		Device:   name,
+		Status:   "up",
+		LastSeen: time.Now(),
+	})
+}
+
+func notifyFailure(cfg *config.Config, notifiers []notify.Notifier, name, message, output string) {
+	msg := cfg.NotificationMessages.Failure
+	if msg == "" {
+		msg = "Failure reported by client: {{name}}. {{message}}"
+	}
+	msg = strings.ReplaceAll(msg, "{{name}}", name)
+	msg = strings.ReplaceAll(msg, "{{message}}", message)
+	if output != "" {
+		msg += "\n\nLast output:\n" + logExcerpt(output)
+	}
+	sendNotification(notifiers, notify.Event{
+		Type:     notify.EventFailure,
+		Subject:  "Dead Man's Switch Failure",
+		Message:  msg,
+		Device:   name,
+		Status:   "failed",
+		LastSeen: time.Now(),
+	})
+}
+
+// recordAcknowledgement stores that the named client's alert was acknowledged,
+// as reported by a notifier. Acknowledgements arriving after the client
+// recovered are ignored.
+func recordAcknowledgement(cfg *config.Config, name, by string, at time.Time) {
+	ch, ok := dbInstance.Get(name)
+	if !ok || !(ch.Missing || ch.Failed) {
+		return
+	}
+	log.Printf("Alert for %s acknowledged by %s", name, by)
+	if err := dbInstance.SetAcknowledged(name, by, at); err != nil {
+		log.Printf("DB update error for %s: %v", name, err)
+		return
+	}
+	broadcastDeviceTable(cfg)
+}
+
+// sendNotification delivers e, stamped with the current time, to all notifiers.
+func sendNotification(notifiers []notify.Notifier, e notify.Event) {
+	e.Time = time.Now()
+	for _, n := range notifiers {
+		if err := notify.Send(n, e); err != nil {
+			log.Printf("Notify error: %v", err)
+		}
+	}
+}
+
+// issueToken generates a new token for the named client and stores its hash.
+func issueToken(name string) (string, error) {
+	token, err := newDeviceToken()
+	if err != nil {
+		return "", err
+	}
+	if err := dbInstance.SetToken(name, token); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// newDeviceToken returns a random UUIDv4-formatted token.
+func newDeviceToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
 // generateDeviceTable creates the device status table HTML
 func generateDeviceTable(cfg *config.Config, heartbeats map[string]db.ClientHeartbeat) string {
 	// Sort device names
@@ -116,7 +307,36 @@ func generateDeviceTable(cfg *config.Config, heartbeats map[string]db.ClientHear
 		htmlBuilder.WriteString("<td>")
 		htmlBuilder.WriteString("<span class='device-name'>")
 		htmlBuilder.WriteString(escapedName)
-		htmlBuilder.WriteString("</span>")
+		htmlBuilder.WriteString("</span> <a class='device-details' href='device/")
+		htmlBuilder.WriteString(html.EscapeString(url.PathEscape(name)))
+		htmlBuilder.WriteString("'>details</a>")
+		if ch.StartedAt != nil {
+			htmlBuilder.WriteString("<br><small class='device-started'>started ")
+			htmlBuilder.WriteString(ch.StartedAt.UTC().Format(time.RFC3339))
+			htmlBuilder.WriteString("</small>")
+		}
+		if ch.ExpiresAt != nil {
+			htmlBuilder.WriteString("<br><small class='device-expiry'>")
+			htmlBuilder.WriteString(html.EscapeString(formatExpiry(*ch.ExpiresAt, time.Now())))
+			htmlBuilder.WriteString("</small>")
+		}
+		if ch.AcknowledgedAt != nil {
+			htmlBuilder.WriteString("<br><small class='device-acknowledged'>acknowledged by ")
+			htmlBuilder.WriteString(html.EscapeString(ch.AcknowledgedBy))
+			htmlBuilder.WriteString(" at ")
+			htmlBuilder.WriteString(ch.AcknowledgedAt.UTC().Format(time.RFC3339))
+			htmlBuilder.WriteString("</small>")
+		}
+		if ch.ConnectedSince != nil {
+			htmlBuilder.WriteString("<br><small class='device-connected'>connected since ")
+			htmlBuilder.WriteString(ch.ConnectedSince.UTC().Format(time.RFC3339))
+			htmlBuilder.WriteString("</small>")
+		}
+		if ch.Message != "" {
+			htmlBuilder.WriteString("<br><small class='device-message'>")
+			htmlBuilder.WriteString(html.EscapeString(ch.Message))
+			htmlBuilder.WriteString("</small>")
+		}
 		htmlBuilder.WriteString("</td>")
 
 		// Last seen cell
@@ -129,7 +349,13 @@ func generateDeviceTable(cfg *config.Config, heartbeats map[string]db.ClientHear
 		// Determine display values based on invert setting
 		var displayValue, statusClass, iconTitle, svgIcon string
 
-		if cfg.Invert {
+		if ch.Failed && !ch.Missing {
+			// A reported failure reads the same in both modes
+			displayValue = "failed"
+			statusClass = "status-yes"
+			iconTitle = "Failed"
+			svgIcon = `<svg xmlns='http://www.w3.org/2000/svg' fill='none' viewBox='0 0 24 24' stroke-width='1.5' stroke='#e53e3e' width='22' height='22'><path stroke-linecap='round' stroke-linejoin='round' d='M12 9v3.75m-9.303 3.376c-.866 1.5.217 3.374 1.948 3.374h14.71c1.73 0 2.813-1.874 1.948-3.374L13.949 3.378c-.866-1.5-3.032-1.5-3.898 0L2.697 16.126ZM12 15.75h.007v.008H12v-.008Z'/></svg>`
+		} else if cfg.Invert {
 			if ch.Missing {
 				displayValue = "no"
 				statusClass = "status-yes"
@@ -241,7 +467,70 @@ func main() {
 			log.Printf("DB close error: %v", err)
 		}
 	}()
+	if err := dbInstance.ResetConnections(time.Now()); err != nil {
+		log.Printf("ResetConnections error: %v", err)
+	}
 	notifiers := setupNotifiers(cfg)
+	notify.OnAcknowledge = func(name, by string, at time.Time) {
+		recordAcknowledgement(cfg, name, by, at)
+	}
+	if cfg.UDPListenAddr != "" {
+		conn, err := net.ListenPacket("udp", cfg.UDPListenAddr)
+		if err != nil {
+			log.Fatalf("Failed to start UDP listener: %v", err)
+		}
+		log.Printf("Listening for UDP heartbeats on %s", conn.LocalAddr())
+		go serveUDP(conn, cfg, notifiers)
+	}
+	if cfg.GRPCListenAddr != "" {
+		l, err := net.Listen("tcp", cfg.GRPCListenAddr)
+		if err != nil {
+			log.Fatalf("Failed to start gRPC listener: %v", err)
+		}
+		grpcServer := newGRPCServer(cfg, notifiers)
+		go func() {
+			log.Printf("Starting gRPC server on %s", l.Addr())
+			if err := grpcServer.Serve(l); err != nil {
+				log.Fatalf("gRPC server: %v", err)
+			}
+		}()
+	}
+	if cfg.MQTT.Broker != "" {
+		client, err := startMQTT(cfg, notifiers)
+		if err != nil {
+			log.Fatalf("Failed to connect to MQTT broker: %v", err)
+		}
+		defer client.Disconnect(250)
+	}
+	if cfg.InboundEmail.ListenAddr != "" {
+		smtpServer := newEmailServer(cfg, notifiers)
+		go func() {
+			log.Printf("Accepting heartbeat emails on %s for domain %s", smtpServer.Addr, smtpServer.Domain)
+			if err := smtpServer.ListenAndServe(); err != nil {
+				log.Fatalf("SMTP receiver: %v", err)
+			}
+		}()
+	}
+	if cfg.Syslog.ListenAddr != "" {
+		rules, err := compileSyslogRules(cfg.Syslog.Rules)
+		if err != nil {
+			log.Fatalf("Invalid syslog config: %v", err)
+		}
+		conn, err := net.ListenPacket("udp", cfg.Syslog.ListenAddr)
+		if err != nil {
+			log.Fatalf("Failed to start syslog UDP listener: %v", err)
+		}
+		l, err := net.Listen("tcp", cfg.Syslog.ListenAddr)
+		if err != nil {
+			log.Fatalf("Failed to start syslog TCP listener: %v", err)
+		}
+		log.Printf("Listening for syslog messages on %s (UDP and TCP)", cfg.Syslog.ListenAddr)
+		go serveSyslogUDP(conn, cfg, notifiers, rules)
+		go serveSyslogTCP(l, cfg, notifiers, rules)
+	}
+	if err := startProbes(context.Background(), cfg, notifiers); err != nil {
+		log.Fatalf("Invalid device config: %v", err)
+	}
 	go monitor(cfg, notifiers)
 	os.Exit(runServer(cfg, notifiers))
 }
@@ -263,6 +552,39 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 	if basePath == "/" {
 		basePath = ""
 	}
+	mux := newMux(cfg, notifiers, basePath)
+
+	server := &http.Server{Addr: cfg.ListenAddr, Handler: securityHeaders(cfg, mux)}
+	go func() {
+		log.Printf("Starting server on %s (basePath: %s)", cfg.ListenAddr, basePath)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("ListenAndServe(): %s", err)
+		}
+	}()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down server...")
+	if err := server.Close(); err != nil {
+		log.Printf("server close error: %v", err)
+	}
+	return 0
+}
+
+// counters holds the listener counters served at /debug/vars. It is not
+// published in the process-wide expvar set, so runtime details like the command
+// line and memory statistics stay private.
+var counters = new(expvar.Map).Init()
+
+// newCounter returns a counter served at /debug/vars under name.
+func newCounter(name string) *expvar.Int {
+	c := new(expvar.Int)
+	counters.Set(name, c)
+	return c
+}
+
+// newMux registers all HTTP routes below basePath.
+func newMux(cfg *config.Config, notifiers []notify.Notifier, basePath string) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc(basePath+"/events", func(w http.ResponseWriter, r *http.Request) {
@@ -311,10 +633,15 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 			return
 		}
 		type req struct {
-			Name string `json:"name"`
+			Name   string `json:"name"`
+			Failed bool   `json:"failed"`
+			Log    string `json:"log"`
 		}
 		var body req
-		err := json.NewDecoder(r.Body).Decode(&body)
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHeartbeatBody))
+		if err == nil {
+			err = json.Unmarshal(raw, &body)
+		}
 		if err != nil || body.Name == "" {
 			w.WriteHeader(http.StatusBadRequest)
 			if _, err := w.Write([]byte("Missing or invalid 'name' in body")); err != nil {
@@ -322,32 +649,34 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 			}
 			return
 		}
-		log.Printf("Received heartbeat from client: %s", body.Name)
-		now := time.Now()
-		// Check if client was missing before updating
-		wasMissing := false
-		if ch, ok := dbInstance.Get(body.Name); ok {
-			wasMissing = ch.Missing
-		}
-		err = dbInstance.UpdateHeartbeat(body.Name, now, false)
-		if err != nil {
-			log.Printf("DB update error for %s: %v", body.Name, err)
-		} else {
-			log.Printf("Stored to DB: {name: %s, timestamp: %s}", body.Name, now.Format(time.RFC3339))
-			broadcastDeviceTable(cfg)
+		if err := checkRequestSignature(cfg, body.Name, r, raw); err != nil {
+			log.Printf("Security event: rejected heartbeat for %s from %s: %v", body.Name, r.RemoteAddr, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			if _, err := w.Write([]byte("Missing or invalid signature")); err != nil {
+				log.Printf("Write error: %v", err)
+			}
+			return
 		}
-		if wasMissing {
-			msg := cfg.NotificationMessages.Recovery
-			if msg == "" {
-				msg = "Heartbeat received again from client: {{name}}"
+		if cfg.RequireToken && !dbInstance.VerifyToken(body.Name, bearerToken(r)) {
+			log.Printf("Rejected heartbeat from client %s: missing or invalid token", body.Name)
+			w.WriteHeader(http.StatusUnauthorized)
+			if _, err := w.Write([]byte("Missing or invalid device token")); err != nil {
+				log.Printf("Write error: %v", err)
 			}
-			msg = strings.ReplaceAll(msg, "{{name}}", body.Name)
-			for _, n := range notifiers {
-				if err := n.Notify("Dead Man's Switch Recovery", msg); err != nil {
-					log.Printf("Notify error: %v", err)
-				}
+			return
+		}
+		log.Printf("Received heartbeat from client: %s", body.Name)
+		if !dbInstance.HasToken(body.Name) {
+			// First contact: hand out a token the client can use from now on
+			token, err := issueToken(body.Name)
+			if err != nil {
+				log.Printf("Token error for %s: %v", body.Name, err)
+			} else {
+				w.Header().Set("X-Device-Token", token)
 			}
 		}
+		output := truncateLog([]byte(body.Log))
+		recordStatus(cfg, notifiers, body.Name, heartbeatStatus{Failed: body.Failed, Message: pingMessage([]byte(body.Log)), Log: output})
 
 		w.WriteHeader(http.StatusOK)
 		if _, err := w.Write([]byte("OK")); err != nil {
@@ -355,6 +684,9 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 		}
 	})
 
+	// /ping/{token}[/start|/fail|/log|/{exit code}] - healthchecks.io-style ping URLs
+	mux.HandleFunc(basePath+"/ping/", pingHandler(cfg, notifiers, basePath))
+
 	mux.HandleFunc(basePath+"/heartbeats", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -372,14 +704,31 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 		}
 	})
 
+	mux.HandleFunc(basePath+"/heartbeats/batch", batchHeartbeatHandler(cfg, notifiers))
+
+	// GET /ws/agent/{name} - WebSocket connection for connection-based liveness
+	mux.HandleFunc(basePath+"/ws/agent/", agentSocketHandler(cfg, notifiers, basePath))
+
+	// POST /webhooks/{path} - configurable inbound webhooks
+	mux.HandleFunc(basePath+"/webhooks/", inboundWebhookHandler(cfg, notifiers, basePath))
+
+	// POST /alertmanager[/{name}] - Alertmanager webhook receiver for Watchdog alerts
+	mux.HandleFunc(basePath+"/alertmanager", alertmanagerHandler(cfg, notifiers, basePath))
+	mux.HandleFunc(basePath+"/alertmanager/", alertmanagerHandler(cfg, notifiers, basePath))
+
 	// DELETE /heartbeats/{name} - remove a device from the DB
+	// POST /heartbeats/{name}/token - issue or rotate a device token
 	mux.HandleFunc(basePath+"/heartbeats/", func(w http.ResponseWriter, r *http.Request) {
+		// Expect the device name as the path suffix
+		name := strings.TrimPrefix(r.URL.Path, basePath+"/heartbeats/")
+		if tokenName, ok := strings.CutSuffix(name, "/token"); ok && tokenName != "" {
+			serveTokenRotation(w, r, cfg, basePath, tokenName)
+			return
+		}
 		if r.Method != http.MethodDelete {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		// Expect the device name as the path suffix
-		name := strings.TrimPrefix(r.URL.Path, basePath+"/heartbeats/")
 		if name == "" {
 			w.WriteHeader(http.StatusBadRequest)
 			if _, err := w.Write([]byte("Missing device name")); err != nil {
@@ -387,7 +736,7 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 			}
 			return
 		}
-		if err := dbInstance.Delete(name); err != nil {
+		if err := deleteDevice(cfg, name); err != nil {
 			log.Printf("DB delete error for %s: %v", name, err)
 			w.WriteHeader(http.StatusInternalServerError)
 			if _, err := w.Write([]byte("DB error")); err != nil {
@@ -395,8 +744,6 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 			}
 			return
 		}
-		// Notify SSE clients of the updated table
-		broadcastDeviceTable(cfg)
 		w.WriteHeader(http.StatusOK)
 		if _, err := w.Write([]byte("OK")); err != nil {
 			log.Printf("Write error: %v", err)
@@ -473,6 +820,14 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 		}
 	})
 
+	// Listener counters (e.g. malformed UDP packets)
+	mux.HandleFunc(basePath+"/debug/vars", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if _, err := w.Write([]byte(counters.String())); err != nil {
+			log.Printf("Write error: %v", err)
+		}
+	})
+
 	mux.HandleFunc(basePath+"/up", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		if _, err := w.Write([]byte("ok")); err != nil {
@@ -484,6 +839,13 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		maskedCfg := *cfg
 		maskedCfg.NotificationChannels = config.MaskChannelSecrets(cfg.NotificationChannels)
+		maskedCfg.Devices = config.MaskDeviceSecrets(cfg.Devices)
+		if maskedCfg.MQTT.Password != "" {
+			maskedCfg.MQTT.Password = config.MaskValue(maskedCfg.MQTT.Password)
+		}
+		if maskedCfg.AdminToken != "" {
+			maskedCfg.AdminToken = config.MaskValue(maskedCfg.AdminToken)
+		}
 		pretty, err := json.MarshalIndent(maskedCfg, "", "  ")
 		if err != nil {
 			http.Error(w, "failed to encode config", http.StatusInternalServerError)
@@ -492,6 +854,9 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 		_, _ = w.Write(pretty)
 	})
 
+	// GET /web/device/{name} - device detail page with the stored job logs
+	mux.HandleFunc(basePath+"/web/device/", deviceDetailHandler(cfg, basePath))
+
 	// Serve static files under /web if needed (e.g. /web/htmx.js)
 	mux.Handle(basePath+"/web/", http.StripPrefix(basePath+"/web/", http.FileServer(http.Dir("web"))))
 
@@ -505,21 +870,62 @@ func runServer(cfg *config.Config, notifiers []notify.Notifier) int {
 		_, _ = w.Write([]byte("Not found"))
 	})
 
-	server := &http.Server{Addr: cfg.ListenAddr, Handler: securityHeaders(cfg, mux)}
-	go func() {
-		log.Printf("Starting server on %s (basePath: %s)", cfg.ListenAddr, basePath)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("ListenAndServe(): %s", err)
+	return mux
+}
+
+// serveTokenRotation issues a fresh token for the named device and returns it once.
+// Any previously issued token stops working immediately. Replacing an existing
+// token requires the current token or the admin token as bearer token.
+func serveTokenRotation(w http.ResponseWriter, r *http.Request, cfg *config.Config, basePath, name string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorizeRotation(cfg, name, bearerToken(r)) {
+		log.Printf("Security event: rejected token rotation for %s from %s: missing or invalid credentials", name, r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		if _, err := w.Write([]byte("Missing or invalid device token")); err != nil {
+			log.Printf("Write error: %v", err)
 		}
-	}()
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("Shutting down server...")
-	if err := server.Close(); err != nil {
-		log.Printf("server close error: %v", err)
+		return
 	}
-	return 0
+	token, err := issueToken(name)
+	if err != nil {
+		log.Printf("Token error for %s: %v", name, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		if _, err := w.Write([]byte("DB error")); err != nil {
+			log.Printf("Write error: %v", err)
+		}
+		return
+	}
+	log.Printf("Issued new token for client: %s", name)
+	w.Header().Set("Content-Type", "application/json")
+	resp := struct {
+		Name    string `json:"name"`
+		Token   string `json:"token"`
+		PingURL string `json:"ping_url"`
+	}{Name: name, Token: token, PingURL: basePath + "/ping/" + token}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Encode error: %v", err)
+	}
+}
+
+// authorizeRotation reports whether token may replace the named device's token:
+// devices without a token may get one freely, otherwise token must be the
+// current device token or the configured admin token.
+func authorizeRotation(cfg *config.Config, name, token string) bool {
+	if !dbInstance.HasToken(name) {
+		return true
+	}
+	if token == "" {
+		return false
+	}
+	return isAdminToken(cfg, token) || dbInstance.VerifyToken(name, token)
+}
+
+// isAdminToken reports whether token is the configured admin token.
+func isAdminToken(cfg *config.Config, token string) bool {
+	return cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) == 1
 }
 
 // securityHeaders returns middleware that sets configurable security headers.
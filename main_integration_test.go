@@ -5,9 +5,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
 	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
 )
 
@@ -136,3 +138,221 @@ func TestDeleteHeartbeatEndpoint_BadRequest(t *testing.T) {
 		t.Fatalf("expected 400, got %d", resp.StatusCode)
 	}
 }
+
+func TestDeviceTokens_RegisterRotateAndPing(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test-integ-tokens.db")
+	var err error
+	dbInstance, err = db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{TimeoutSeconds: 600}
+	ts := httptest.NewServer(newMux(cfg, nil, ""))
+	defer ts.Close()
+
+	// First heartbeat registers the device and hands out a token
+	resp, err := http.Post(ts.URL+"/heartbeat", "application/json", strings.NewReader(`{"name":"clientA"}`))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	token := resp.Header.Get("X-Device-Token")
+	if resp.StatusCode != http.StatusOK || token == "" {
+		t.Fatalf("expected 200 with X-Device-Token, got %d %q", resp.StatusCode, token)
+	}
+
+	// Subsequent heartbeats do not leak the token again
+	resp, err = http.Post(ts.URL+"/heartbeat", "application/json", strings.NewReader(`{"name":"clientA"}`))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Device-Token"); got != "" {
+		t.Errorf("token re-issued on known device: %q", got)
+	}
+
+	// Rotate with the current token and check that only the new token is accepted on the ping URL
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/heartbeats/clientA/token", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	var rotated struct {
+		Name    string `json:"name"`
+		Token   string `json:"token"`
+		PingURL string `json:"ping_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rotated); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	resp.Body.Close()
+	if rotated.Name != "clientA" || rotated.Token == "" || rotated.Token == token {
+		t.Fatalf("unexpected rotation response: %+v", rotated)
+	}
+	if rotated.PingURL != "/ping/"+rotated.Token {
+		t.Errorf("unexpected ping_url %q", rotated.PingURL)
+	}
+
+	resp, err = http.Get(ts.URL + "/ping/" + token)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("old token: expected 404, got %d", resp.StatusCode)
+	}
+	resp, err = http.Get(ts.URL + rotated.PingURL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("new token: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestDeviceTokens_RotationRequiresCredentials(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test-integ-tokens-rotation.db")
+	var err error
+	dbInstance, err = db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+	if err := dbInstance.SetToken("victim", "victim-token"); err != nil {
+		t.Fatalf("set token: %v", err)
+	}
+
+	cfg := &config.Config{TimeoutSeconds: 600, RequireToken: true, AdminToken: "admin-secret"}
+	ts := httptest.NewServer(newMux(cfg, nil, ""))
+	defer ts.Close()
+
+	rotate := func(name, bearer string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/heartbeats/"+name+"/token", nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("rotate failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := rotate("victim", ""); code != http.StatusUnauthorized {
+		t.Errorf("rotation without credentials: expected 401, got %d", code)
+	}
+	if code := rotate("victim", "wrong-token"); code != http.StatusUnauthorized {
+		t.Errorf("rotation with wrong token: expected 401, got %d", code)
+	}
+	if !dbInstance.VerifyToken("victim", "victim-token") {
+		t.Fatal("rejected rotation must keep the current token")
+	}
+	if code := rotate("victim", "victim-token"); code != http.StatusOK {
+		t.Errorf("rotation with current token: expected 200, got %d", code)
+	}
+	if code := rotate("victim", "admin-secret"); code != http.StatusOK {
+		t.Errorf("rotation with admin token: expected 200, got %d", code)
+	}
+	// Devices without a token can still be registered
+	if code := rotate("newcomer", ""); code != http.StatusOK {
+		t.Errorf("first token for new device: expected 200, got %d", code)
+	}
+}
+
+func TestDeviceTokens_DeleteDoesNotReleaseName(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test-integ-tokens-delete.db")
+	var err error
+	dbInstance, err = db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+	if err := dbInstance.SetToken("victim", "victim-token"); err != nil {
+		t.Fatalf("set token: %v", err)
+	}
+
+	cfg := &config.Config{TimeoutSeconds: 600, RequireToken: true}
+	ts := httptest.NewServer(newMux(cfg, nil, ""))
+	defer ts.Close()
+
+	do := func(method, path, bearer, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := do(http.MethodDelete, "/heartbeats/victim", "", ""); code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", code)
+	}
+	// Re-registering the deleted name must not hand out a new token
+	if code := do(http.MethodPost, "/heartbeats/victim/token", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("register after delete: expected 401, got %d", code)
+	}
+	if code := do(http.MethodPost, "/heartbeat", "", `{"name":"victim"}`); code != http.StatusUnauthorized {
+		t.Errorf("heartbeat without token after delete: expected 401, got %d", code)
+	}
+	// The device itself keeps working with its token
+	if code := do(http.MethodPost, "/heartbeat", "victim-token", `{"name":"victim"}`); code != http.StatusOK {
+		t.Errorf("heartbeat with the old token: expected 200, got %d", code)
+	}
+}
+
+func TestDeviceTokens_Required(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test-integ-tokens-required.db")
+	var err error
+	dbInstance, err = db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+	if err := dbInstance.SetToken("clientA", "good-token"); err != nil {
+		t.Fatalf("set token: %v", err)
+	}
+
+	cfg := &config.Config{TimeoutSeconds: 600, RequireToken: true}
+	ts := httptest.NewServer(newMux(cfg, nil, ""))
+	defer ts.Close()
+
+	tests := []struct {
+		name, device, auth string
+		want               int
+	}{
+		{"no token", "clientA", "", http.StatusUnauthorized},
+		{"wrong token", "clientA", "Bearer bad-token", http.StatusUnauthorized},
+		{"unknown device", "clientB", "Bearer good-token", http.StatusUnauthorized},
+		{"valid token", "clientA", "Bearer good-token", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+"/heartbeat", strings.NewReader(`{"name":"`+tt.device+`"}`))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("POST failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+	if _, ok := dbInstance.Get("clientB"); ok {
+		t.Error("rejected heartbeat must not be stored")
+	}
+}
@@ -2,16 +2,40 @@ package main
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
 	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
 )
 
+// recordingNotifier collects all notifications it receives.
+type recordingNotifier struct {
+	mu       sync.Mutex
+	subjects []string
+	messages []string
+}
+
+func (n *recordingNotifier) Notify(subject, message string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subjects = append(n.subjects, subject)
+	n.messages = append(n.messages, message)
+	return nil
+}
+
+func (n *recordingNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.subjects)
+}
+
 func TestHeartbeatEndpoint(t *testing.T) {
 	dbPath := t.TempDir() + "/test-heartbeats.db"
 	dbInstance, _ = db.Open(dbPath)
@@ -391,3 +415,122 @@ func TestGenerateDeviceTableEmpty(t *testing.T) {
 		t.Error("expected empty tbody for no devices")
 	}
 }
+
+func TestRecordStatusNotifications(t *testing.T) {
+	dbInstance, _ = db.Open(t.TempDir() + "/test-record-status.db")
+	defer dbInstance.Close()
+
+	rec := &recordingNotifier{}
+	cfg := &config.Config{TimeoutSeconds: 600}
+	notifiers := []notify.Notifier{rec}
+
+	steps := []struct {
+		status heartbeatStatus
+		want   []string
+	}{
+		{heartbeatStatus{}, nil},
+		{heartbeatStatus{Failed: true, Message: "disk full"}, []string{"Dead Man's Switch Failure"}},
+		// Repeated failures are only reported once
+		{heartbeatStatus{Failed: true, Message: "disk still full"}, []string{"Dead Man's Switch Failure"}},
+		{heartbeatStatus{}, []string{"Dead Man's Switch Failure", "Dead Man's Switch Recovery"}},
+	}
+	for i, step := range steps {
+		recordStatus(cfg, notifiers, "job", step.status)
+		if strings.Join(rec.subjects, "|") != strings.Join(step.want, "|") {
+			t.Errorf("step %d: notifications = %v, want %v", i, rec.subjects, step.want)
+		}
+	}
+	ch, _ := dbInstance.Get("job")
+	if ch.Failed || ch.Message != "" {
+		t.Errorf("expected cleared status after success, got %+v", ch)
+	}
+}
+
+func TestGenerateDeviceTableFailed(t *testing.T) {
+	cfg := &config.Config{Invert: false}
+	heartbeats := map[string]db.ClientHeartbeat{
+		"job": {Name: "job", Timestamp: time.Now(), Failed: true, Message: "<b>disk full</b>"},
+	}
+	html := generateDeviceTable(cfg, heartbeats)
+	if !strings.Contains(html, "<span class='status-text'>failed</span>") {
+		t.Error("expected failed status text")
+	}
+	if !strings.Contains(html, "&lt;b&gt;disk full&lt;/b&gt;") {
+		t.Error("expected escaped message below the device name")
+	}
+}
+
+func TestRecordAcknowledgement(t *testing.T) {
+	dbInstance, _ = db.Open(t.TempDir() + "/test-ack.db")
+	defer dbInstance.Close()
+
+	cfg := &config.Config{TimeoutSeconds: 600}
+	at := time.Now().Truncate(time.Second)
+	recordHeartbeat(cfg, nil, "nas")
+	// Not alerting, so nothing to acknowledge
+	recordAcknowledgement(cfg, "nas", "phone", at)
+	if ch, _ := dbInstance.Get("nas"); ch.AcknowledgedAt != nil {
+		t.Fatalf("acknowledgement recorded for healthy device: %+v", ch)
+	}
+
+	if err := dbInstance.SetMissing("nas", true); err != nil {
+		t.Fatal(err)
+	}
+	recordAcknowledgement(cfg, "nas", "phone", at)
+	ch, _ := dbInstance.Get("nas")
+	if ch.AcknowledgedAt == nil || !ch.AcknowledgedAt.Equal(at) || ch.AcknowledgedBy != "phone" {
+		t.Fatalf("acknowledgement not recorded: %+v", ch)
+	}
+	if html := generateDeviceTable(cfg, map[string]db.ClientHeartbeat{"nas": ch}); !strings.Contains(html, "acknowledged by phone") {
+		t.Error("expected acknowledgement in device table")
+	}
+
+	recordHeartbeat(cfg, nil, "nas")
+	if ch, _ := dbInstance.Get("nas"); ch.AcknowledgedAt != nil {
+		t.Errorf("acknowledgement should be cleared by the next heartbeat: %+v", ch)
+	}
+}
+
+func TestDebugVarsServesOnlyCounters(t *testing.T) {
+	ts := httptest.NewServer(newMux(&config.Config{TimeoutSeconds: 600}, nil, ""))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/debug/vars")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var vars map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	for _, name := range []string{"udp_packets_received", "syslog_messages_malformed"} {
+		if _, ok := vars[name]; !ok {
+			t.Errorf("counter %s missing: %v", name, vars)
+		}
+	}
+	for _, name := range []string{"cmdline", "memstats"} {
+		if _, ok := vars[name]; ok {
+			t.Errorf("%s must not be exposed", name)
+		}
+	}
+}
+
+func TestWebConfigMasksSecrets(t *testing.T) {
+	cfg := &config.Config{TimeoutSeconds: 600, AdminToken: "admin-secret-token"}
+	cfg.MQTT.Password = "mqtt-secret-password"
+	ts := httptest.NewServer(newMux(cfg, nil, ""))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/web/config")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	for _, secret := range []string{cfg.AdminToken, cfg.MQTT.Password} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("config response leaks %q: %s", secret, body)
+		}
+	}
+}
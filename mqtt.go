@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+const defaultMQTTClientID = "dead-mans-switch"
+
+// mqttPayload is the optional JSON body of a heartbeat message.
+type mqttPayload struct {
+	Name      string `json:"name"`
+	Token     string `json:"token"`
+	Timestamp string `json:"ts"`
+	Signature string `json:"sig"`
+}
+
+// startMQTT connects to the configured broker and subscribes to all heartbeat topics.
+// Subscriptions are renewed whenever the client reconnects.
+func startMQTT(cfg *config.Config, notifiers []notify.Notifier) (mqtt.Client, error) {
+	clientID := cfg.MQTT.ClientID
+	if clientID == "" {
+		clientID = defaultMQTTClientID
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.MQTT.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.MQTT.Username).
+		SetPassword(cfg.MQTT.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true)
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		for _, pattern := range cfg.MQTT.Topics {
+			token := c.Subscribe(pattern, 1, func(_ mqtt.Client, msg mqtt.Message) {
+				handleMQTTMessage(cfg, notifiers, pattern, msg.Topic(), msg.Payload())
+			})
+			if token.Wait() && token.Error() != nil {
+				log.Printf("MQTT subscribe error for %s: %v", pattern, token.Error())
+				continue
+			}
+			log.Printf("Subscribed to MQTT topic %s", pattern)
+		}
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		log.Printf("MQTT connection lost: %v", err)
+	})
+
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	if !token.WaitTimeout(10 * time.Second) {
+		// With SetConnectRetry the client keeps trying in the background
+		log.Printf("MQTT broker %s not reachable yet, retrying in background", cfg.MQTT.Broker)
+		return client, nil
+	}
+	return client, token.Error()
+}
+
+func handleMQTTMessage(cfg *config.Config, notifiers []notify.Notifier, pattern, topic string, payload []byte) {
+	name, p, err := mqttDevice(pattern, topic, payload)
+	if err != nil {
+		log.Printf("Ignoring MQTT message on %s: %v", topic, err)
+		return
+	}
+	if err := authorizeCompact(cfg, name, p.Token, p.Timestamp, p.Signature); err != nil {
+		log.Printf("Security event: rejected MQTT heartbeat for %s on %s: %v", name, topic, err)
+		return
+	}
+	log.Printf("Received MQTT heartbeat from client: %s", name)
+	recordHeartbeat(cfg, notifiers, name)
+}
+
+// mqttDevice derives the device name of a message. If the subscription pattern
+// contains a single-level wildcard, the topic level matched by the first "+" is the
+// name. Otherwise the name is read from a JSON payload ({"name": ...}) or, failing
+// that, taken from the raw payload text.
+func mqttDevice(pattern, topic string, payload []byte) (string, mqttPayload, error) {
+	var p mqttPayload
+	if trimmed := strings.TrimSpace(string(payload)); strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal([]byte(trimmed), &p); err != nil {
+			return "", p, err
+		}
+	} else if trimmed != "" {
+		p.Name = trimmed
+	}
+	name := topicWildcard(pattern, topic)
+	if name == "" {
+		name = p.Name
+	}
+	if name == "" {
+		return "", p, errors.New("no device name in topic or payload")
+	}
+	return name, p, nil
+}
+
+// topicWildcard returns the topic level matched by the first "+" in pattern, or "".
+func topicWildcard(pattern, topic string) string {
+	patternLevels := strings.Split(pattern, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range patternLevels {
+		if level == "+" && i < len(topicLevels) {
+			return topicLevels[i]
+		}
+	}
+	return ""
+}
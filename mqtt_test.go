@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+)
+
+func TestMQTTDevice(t *testing.T) {
+	tests := []struct {
+		name, pattern, topic, payload string
+		want                          string
+		wantErr                       bool
+	}{
+		{"wildcard in topic", "devices/+/heartbeat", "devices/sensor1/heartbeat", "", "sensor1", false},
+		{"topic wins over payload", "devices/+/heartbeat", "devices/sensor1/heartbeat", `{"name":"other"}`, "sensor1", false},
+		{"json payload", "heartbeats", "heartbeats", `{"name":"sensor2","token":"t"}`, "sensor2", false},
+		{"plain payload", "heartbeats/#", "heartbeats/x", "sensor3\n", "sensor3", false},
+		{"no name", "heartbeats", "heartbeats", "", "", true},
+		{"invalid json", "heartbeats", "heartbeats", `{"name":`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := mqttDevice(tt.pattern, tt.topic, []byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mqttDevice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("mqttDevice() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMQTTSubscriber(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-mqtt.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	// Reserve a free port for the embedded broker
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	broker := mochi.New(&mochi.Options{InlineClient: true})
+	if err := broker.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("add hook: %v", err)
+	}
+	if err := broker.AddListener(listeners.NewTCP(listeners.Config{ID: "test", Address: addr})); err != nil {
+		t.Fatalf("add listener: %v", err)
+	}
+	go func() {
+		_ = broker.Serve()
+	}()
+	defer broker.Close()
+
+	cfg := &config.Config{
+		TimeoutSeconds: 600,
+		MQTT: config.MQTT{
+			Broker: "tcp://" + addr,
+			Topics: []string{"devices/+/heartbeat", "heartbeats"},
+		},
+	}
+	client, err := startMQTT(cfg, nil)
+	if err != nil {
+		t.Fatalf("startMQTT: %v", err)
+	}
+	defer client.Disconnect(100)
+
+	waitFor := func(name string) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			if _, ok := dbInstance.Get(name); ok {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("heartbeat for %s not recorded", name)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// Subscriptions are set up asynchronously in the connect handler; publish until seen
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if err := broker.Publish("devices/sensor1/heartbeat", []byte("ping"), false, 1); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+		if _, ok := dbInstance.Get("sensor1"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("heartbeat for sensor1 not recorded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := broker.Publish("heartbeats", []byte(`{"name":"sensor2"}`), false, 1); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	waitFor("sensor2")
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Discord embed limits
+const (
+	discordMaxTitle       = 256
+	discordMaxDescription = 4096
+)
+
+// DiscordNotifier posts embeds to a Discord channel webhook.
+type DiscordNotifier struct {
+	WebhookURL string
+	Username   string
+}
+
+func (d *DiscordNotifier) Notify(subject, message string) error {
+	return d.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+func (d *DiscordNotifier) NotifyEvent(e Event) error {
+	payload := map[string]any{"embeds": []any{discordEmbed(e)}}
+	if d.Username != "" {
+		payload["username"] = d.Username
+	}
+	return postJSON(d.WebhookURL, nil, payload, nil)
+}
+
+// discordEmbed formats e as embed coloured by status, with fields for the
+// device, how long it has been silent and when it was last seen.
+func discordEmbed(e Event) map[string]any {
+	var fields []any
+	field := func(name, value string) {
+		fields = append(fields, map[string]any{"name": name, "value": value, "inline": true})
+	}
+	if e.Device != "" {
+		field("Device", e.Device)
+	}
+	if d := silence(e); d > 0 {
+		field("Duration", d.String())
+	}
+	if !e.LastSeen.IsZero() {
+		field("Last seen", fmt.Sprintf("<t:%d:f>", e.LastSeen.Unix()))
+	}
+	embed := map[string]any{
+		"title":       truncate(e.Subject, discordMaxTitle),
+		"description": truncate(e.Message, discordMaxDescription),
+		"color":       colorInt(eventColor(e.Type)),
+	}
+	if fields != nil {
+		embed["fields"] = fields
+	}
+	if !e.Time.IsZero() {
+		embed["timestamp"] = e.Time.UTC().Format(time.RFC3339)
+	}
+	return embed
+}
+
+func NewDiscordNotifier(props map[string]string) Notifier {
+	if props["webhook_url"] == "" {
+		log.Printf("discord notifier: webhook_url is required")
+		return nil
+	}
+	return &DiscordNotifier{WebhookURL: props["webhook_url"], Username: props["username"]}
+}
+
+func init() {
+	Register("discord", NewDiscordNotifier)
+}
@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxExecStderr caps how much of a command's stderr is logged.
+	maxExecStderr = 4 << 10
+	// execWorkers limits how many commands run at once; up to execQueueSize
+	// further notifications wait for a free worker, later ones are dropped.
+	execWorkers   = 4
+	execQueueSize = 64
+)
+
+// ExecNotifier runs a local command for every notification. The event is passed
+// as DMS_* environment variables and as JSON on stdin. Commands run in the
+// background on a few workers, so a slow script never delays the monitor loop;
+// their outcome is only logged.
+type ExecNotifier struct {
+	Command string
+	Args    []string
+	Timeout time.Duration
+
+	once  sync.Once
+	queue chan Event
+}
+
+func (x *ExecNotifier) Notify(subject, message string) error {
+	return x.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+func (x *ExecNotifier) NotifyEvent(e Event) error {
+	x.once.Do(x.start)
+	select {
+	case x.queue <- e:
+		return nil
+	default:
+		return fmt.Errorf("exec: %d notifications pending, dropping %q", execQueueSize, e.Subject)
+	}
+}
+
+// start creates the queue and its workers on first use.
+func (x *ExecNotifier) start() {
+	x.queue = make(chan Event, execQueueSize)
+	for range execWorkers {
+		go func() {
+			for e := range x.queue {
+				_ = x.run(e)
+			}
+		}()
+	}
+}
+
+// run executes the command for e, waits for it and logs the outcome.
+func (x *ExecNotifier) run(e Event) error {
+	stdin, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), x.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, x.Command, x.Args...)
+	cmd.Env = append(os.Environ(), execEnv(e)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr limitedBuffer
+	cmd.Stderr = &stderr
+	// Do not wait for children that keep stderr open after a kill
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	took := time.Since(start).Round(time.Millisecond)
+	output := strings.TrimSpace(stderr.String())
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("timed out after %s", x.Timeout)
+	case errors.As(err, &exitErr):
+		err = fmt.Errorf("exit status %d", exitErr.ExitCode())
+	}
+	if err != nil {
+		log.Printf("exec notifier: %s failed after %s: %v; stderr: %s", x.Command, took, err, output)
+		return err
+	}
+	if output != "" {
+		log.Printf("exec notifier: %s succeeded after %s; stderr: %s", x.Command, took, output)
+	} else {
+		log.Printf("exec notifier: %s succeeded after %s", x.Command, took)
+	}
+	return nil
+}
+
+// execEnv returns the event fields as environment variables.
+func execEnv(e Event) []string {
+	env := []string{
+		"DMS_EVENT_TYPE=" + e.Type,
+		"DMS_SUBJECT=" + e.Subject,
+		"DMS_MESSAGE=" + e.Message,
+		"DMS_DEVICE=" + e.Device,
+		"DMS_STATUS=" + e.Status,
+	}
+	if !e.LastSeen.IsZero() {
+		env = append(env, "DMS_LAST_SEEN="+e.LastSeen.UTC().Format(time.RFC3339))
+	}
+	if !e.Time.IsZero() {
+		env = append(env, "DMS_TIME="+e.Time.UTC().Format(time.RFC3339))
+	}
+	return env
+}
+
+// limitedBuffer keeps the first maxExecStderr bytes written to it and discards
+// the rest, so a chatty command cannot fill memory.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxExecStderr - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func NewExecNotifier(props map[string]string) Notifier {
+	x := &ExecNotifier{Command: props["command"]}
+	if x.Command == "" {
+		log.Printf("exec notifier: command is required")
+		return nil
+	}
+	// One argument per line, so arguments may contain spaces
+	for _, arg := range strings.Split(props["args"], "\n") {
+		if arg = strings.TrimSpace(arg); arg != "" {
+			x.Args = append(x.Args, arg)
+		}
+	}
+	seconds, err := intProp(props, "timeout_seconds", 30)
+	if err != nil || seconds <= 0 {
+		log.Printf("exec notifier: timeout_seconds must be a positive number, got %q", props["timeout_seconds"])
+		return nil
+	}
+	x.Timeout = time.Duration(seconds) * time.Second
+	return x
+}
+
+func init() {
+	Register("exec", NewExecNotifier)
+}
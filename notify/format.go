@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status colours shared by the chat notifiers.
+const (
+	colorAlert    = "#e53e3e"
+	colorRecovery = "#38a169"
+	colorWarning  = "#dd6b20"
+	colorNeutral  = "#718096"
+)
+
+// eventColor returns the status colour for an event type.
+func eventColor(eventType string) string {
+	switch eventType {
+	case EventTimeout, EventFailure:
+		return colorAlert
+	case EventRecovery:
+		return colorRecovery
+	case EventExpiry:
+		return colorWarning
+	}
+	return colorNeutral
+}
+
+// colorInt converts a "#rrggbb" colour to the integer form some APIs expect.
+func colorInt(color string) int {
+	n, _ := strconv.ParseInt(strings.TrimPrefix(color, "#"), 16, 32)
+	return int(n)
+}
+
+// silence returns how long the device had been quiet when e was raised,
+// rounded to seconds; zero if unknown.
+func silence(e Event) time.Duration {
+	if e.LastSeen.IsZero() || e.Time.IsZero() {
+		return 0
+	}
+	return max(e.Time.Sub(e.LastSeen).Round(time.Second), 0)
+}
+
+// truncate shortens s to at most n runes, marking the cut with "...".
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
+
+// eventPriorities returns the priority per event type: defaults, overridden by
+// "priority_<type>" properties.
+func eventPriorities(props map[string]string, defaults map[string]int) (map[string]int, error) {
+	priorities := map[string]int{}
+	for t, p := range defaults {
+		priorities[t] = p
+		if v := props["priority_"+t]; v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("priority_%s: %w", t, err)
+			}
+			priorities[t] = n
+		}
+	}
+	return priorities, nil
+}
+
+// clickURL fills {{name}} in a configured link with the event's device name.
+func clickURL(link string, e Event) string {
+	return strings.ReplaceAll(link, "{{name}}", url.PathEscape(e.Device))
+}
+
+// splitList splits a comma-separated property, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
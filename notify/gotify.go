@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// gotifyEmojis prefix the title per event type, as Gotify has no tags.
+var gotifyEmojis = map[string]string{
+	EventTimeout:  "\U0001F6A8",
+	EventFailure:  "\u274C",
+	EventRecovery: "\u2705",
+	EventExpiry:   "\u26A0\uFE0F",
+}
+
+// GotifyNotifier sends messages to a Gotify server using an application token.
+type GotifyNotifier struct {
+	ServerURL  string
+	AppToken   string
+	Click      string
+	priorities map[string]int
+}
+
+func (g *GotifyNotifier) Notify(subject, message string) error {
+	return g.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+func (g *GotifyNotifier) NotifyEvent(e Event) error {
+	title := e.Subject
+	if emoji, ok := gotifyEmojis[e.Type]; ok {
+		title = emoji + " " + title
+	}
+	payload := map[string]any{
+		"title":   title,
+		"message": e.Message,
+	}
+	if p, ok := g.priorities[e.Type]; ok {
+		payload["priority"] = p
+	}
+	if g.Click != "" {
+		payload["extras"] = map[string]any{
+			"client::notification": map[string]any{
+				"click": map[string]any{"url": clickURL(g.Click, e)},
+			},
+		}
+	}
+	header := http.Header{"X-Gotify-Key": {g.AppToken}}
+	if err := postJSON(g.ServerURL+"/message", header, payload, nil); err != nil {
+		return fmt.Errorf("gotify: %w", err)
+	}
+	return nil
+}
+
+func NewGotifyNotifier(props map[string]string) Notifier {
+	g := &GotifyNotifier{
+		ServerURL: strings.TrimSuffix(props["server_url"], "/"),
+		AppToken:  props["app_token"],
+		Click:     props["click_url"],
+	}
+	var err error
+	switch {
+	case g.ServerURL == "":
+		err = errors.New("server_url is required")
+	case g.AppToken == "":
+		err = errors.New("app_token is required")
+	default:
+		// Gotify clients treat 8 and above as high, 4-7 as default priority
+		g.priorities, err = eventPriorities(props, map[string]int{
+			EventTimeout:  8,
+			EventFailure:  8,
+			EventExpiry:   5,
+			EventRecovery: 5,
+		})
+	}
+	if err != nil {
+		log.Printf("gotify notifier: %v", err)
+		return nil
+	}
+	return g
+}
+
+func init() {
+	Register("gotify", NewGotifyNotifier)
+}
@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpClient is shared by all notifiers talking to HTTP APIs.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON sends payload as JSON to url and, if out is not nil, decodes the JSON
+// response into it. Responses outside 2xx are returned as errors.
+func postJSON(url string, header http.Header, payload, out any) error {
+	return sendJSON(http.MethodPost, url, header, payload, out)
+}
+
+// sendJSON is postJSON with another request method.
+func sendJSON(method, url string, header http.Header, payload, out any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doJSON(req, out)
+}
+
+// postForm sends form as URL-encoded body to u, like postJSON.
+func postForm(u string, form url.Values, out any) error {
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doJSON(req, out)
+}
+
+// getJSON fetches u and decodes the JSON response into out.
+func getJSON(u string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(req, out)
+}
+
+// statusError is returned for responses outside 2xx; Body holds the start of
+// the response body.
+type statusError struct {
+	Host       string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.Host, e.StatusCode, e.Body)
+}
+
+// doJSON sends req and, if out is not nil, decodes the JSON response into it.
+// Responses outside 2xx are returned as errors including the start of the body.
+func doJSON(req *http.Request, out any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{Host: req.URL.Host, StatusCode: resp.StatusCode, Header: resp.Header, Body: bytes.TrimSpace(msg)}
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
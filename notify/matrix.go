@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Matrix rate-limit handling. Notifications are sent synchronously from the
+// monitor loop and heartbeat handlers, so all retries of one message together
+// may wait at most matrixRetryBudget.
+const (
+	matrixMaxRetries  = 3
+	matrixRetryBudget = 10 * time.Second
+)
+
+// matrixTxn makes transaction IDs unique within this process.
+var matrixTxn atomic.Uint64
+
+// MatrixNotifier sends messages to a Matrix room through the client-server API.
+type MatrixNotifier struct {
+	HomeserverURL string
+	AccessToken   string
+	RoomID        string
+}
+
+func (m *MatrixNotifier) Notify(subject, message string) error {
+	return m.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+// NotifyEvent sends e as HTML message with a plain-text body. Rate-limited
+// requests are retried after the wait the homeserver asks for, as long as it
+// fits into the retry budget; the transaction ID stays the same, so a retry
+// never posts the message twice.
+func (m *MatrixNotifier) NotifyEvent(e Event) error {
+	txn := fmt.Sprintf("dms-%d-%d", time.Now().UnixNano(), matrixTxn.Add(1))
+	u := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		m.HomeserverURL, url.PathEscape(m.RoomID), txn)
+	header := http.Header{"Authorization": {"Bearer " + m.AccessToken}}
+	payload := matrixMessage(e)
+	budget := matrixRetryBudget
+	for attempt := 0; ; attempt++ {
+		err := sendJSON(http.MethodPut, u, header, payload, nil)
+		var se *statusError
+		if !errors.As(err, &se) || se.StatusCode != http.StatusTooManyRequests || attempt == matrixMaxRetries {
+			if err != nil {
+				return fmt.Errorf("matrix: %w", err)
+			}
+			return nil
+		}
+		wait := matrixRetryAfter(se)
+		if wait > budget {
+			return fmt.Errorf("matrix: rate limited for %s, giving up: %w", wait, err)
+		}
+		budget -= wait
+		log.Printf("matrix: rate limited, retrying in %s", wait)
+		time.Sleep(wait)
+	}
+}
+
+// matrixRetryAfter returns how long a rate-limited request should wait, from
+// retry_after_ms in the body or the Retry-After header.
+func matrixRetryAfter(se *statusError) time.Duration {
+	wait := time.Second
+	var body struct {
+		RetryAfterMS int64 `json:"retry_after_ms"`
+	}
+	if json.Unmarshal(se.Body, &body) == nil && body.RetryAfterMS > 0 {
+		wait = time.Duration(body.RetryAfterMS) * time.Millisecond
+	} else if s, err := strconv.Atoi(se.Header.Get("Retry-After")); err == nil && s > 0 {
+		wait = time.Duration(s) * time.Second
+	}
+	return wait
+}
+
+// matrixMessage formats e as m.text message; formatted_body carries the HTML
+// version for clients that render it.
+func matrixMessage(e Event) map[string]any {
+	var plain, rich strings.Builder
+	plain.WriteString(e.Subject + "\n" + e.Message)
+	fmt.Fprintf(&rich, "<h4><font color=\"%s\">%s</font></h4><p>%s</p>",
+		eventColor(e.Type), html.EscapeString(e.Subject),
+		strings.ReplaceAll(html.EscapeString(e.Message), "\n", "<br>"))
+	var details []string
+	if e.Device != "" {
+		details = append(details, "<li><b>Device:</b> "+html.EscapeString(e.Device)+"</li>")
+		plain.WriteString("\nDevice: " + e.Device)
+	}
+	if !e.LastSeen.IsZero() {
+		lastSeen := e.LastSeen.UTC().Format(time.RFC3339)
+		details = append(details, "<li><b>Last seen:</b> "+lastSeen+"</li>")
+		plain.WriteString("\nLast seen: " + lastSeen)
+	}
+	if details != nil {
+		rich.WriteString("<ul>" + strings.Join(details, "") + "</ul>")
+	}
+	return map[string]any{
+		"msgtype":        "m.text",
+		"body":           plain.String(),
+		"format":         "org.matrix.custom.html",
+		"formatted_body": rich.String(),
+	}
+}
+
+func NewMatrixNotifier(props map[string]string) Notifier {
+	m := &MatrixNotifier{
+		HomeserverURL: strings.TrimSuffix(props["homeserver_url"], "/"),
+		AccessToken:   props["access_token"],
+		RoomID:        props["room_id"],
+	}
+	if m.HomeserverURL == "" || m.AccessToken == "" || m.RoomID == "" {
+		log.Printf("matrix notifier: homeserver_url, access_token and room_id are required")
+		return nil
+	}
+	return m
+}
+
+func init() {
+	Register("matrix", NewMatrixNotifier)
+}
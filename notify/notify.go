@@ -1,10 +1,58 @@
 package notify
 
+import "time"
+
 // Notifier is the interface for all notification channels.
 type Notifier interface {
 	Notify(subject, message string) error
 }
 
+// Event types passed in Event.Type.
+const (
+	EventTimeout  = "timeout"
+	EventRecovery = "recovery"
+	EventFailure  = "failure"
+	EventExpiry   = "expiry"
+)
+
+// Event describes what triggered a notification.
+type Event struct {
+	Type     string    `json:"type"`
+	Subject  string    `json:"subject"`
+	Message  string    `json:"message"`
+	Device   string    `json:"device"`
+	Status   string    `json:"status"`
+	LastSeen time.Time `json:"last_seen"`
+	Time     time.Time `json:"time"`
+}
+
+// EventNotifier is implemented by notifiers that build their own payload from
+// the full event instead of subject and message only.
+type EventNotifier interface {
+	Notifier
+	NotifyEvent(e Event) error
+}
+
+// Send delivers e through n, using NotifyEvent if n implements EventNotifier.
+func Send(n Notifier, e Event) error {
+	if en, ok := n.(EventNotifier); ok {
+		return en.NotifyEvent(e)
+	}
+	return n.Notify(e.Subject, e.Message)
+}
+
+// OnAcknowledge, if set, is called when a recipient acknowledges the alert for
+// a device through a notifier that supports acknowledgements, e.g. Pushover
+// emergency notifications.
+var OnAcknowledge func(device, by string, at time.Time)
+
+// acknowledge reports an acknowledgement to OnAcknowledge.
+func acknowledge(device, by string, at time.Time) {
+	if OnAcknowledge != nil {
+		OnAcknowledge(device, by, at)
+	}
+}
+
 // Registry for pluggable notifiers
 var notifiers = map[string]func(map[string]string) Notifier{}
 
@@ -1,6 +1,25 @@
 package notify
 
-import "testing"
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 type testNotifier struct {
 	called []string
@@ -153,3 +172,744 @@ func TestDummyNotifier(t *testing.T) {
 		}
 	}
 }
+
+type eventNotifier struct {
+	testNotifier
+	events []Event
+}
+
+func (e *eventNotifier) NotifyEvent(ev Event) error {
+	e.events = append(e.events, ev)
+	return nil
+}
+
+func TestSend(t *testing.T) {
+	ev := Event{Type: EventFailure, Subject: "subj", Message: "msg", Device: "backup"}
+	plain := &testNotifier{}
+	if err := Send(plain, ev); err != nil || len(plain.called) != 1 || plain.called[0] != "subj:msg" {
+		t.Errorf("expected fallback to Notify, got %v, %v", plain.called, err)
+	}
+	rich := &eventNotifier{}
+	if err := Send(rich, ev); err != nil || len(rich.events) != 1 || len(rich.called) != 0 {
+		t.Errorf("expected NotifyEvent, got events %v, calls %v, %v", rich.events, rich.called, err)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var gotMethod, gotBody string
+	var gotHeader http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotMethod, gotBody, gotHeader = r.Method, string(body), r.Header
+	}))
+	defer srv.Close()
+
+	n := CreateNotifier("webhook", map[string]string{
+		"url":     srv.URL,
+		"method":  "put",
+		"headers": "Authorization: Bearer abc\nX-Env: prod",
+		"body":    `{"text": {{json .Message}}, "device": {{json .Device}}, "status": "{{.Status}}"}`,
+		"secret":  "s3cret",
+	})
+	if n == nil {
+		t.Fatal("webhook notifier not created")
+	}
+	ev := Event{Type: EventTimeout, Subject: "Triggered", Message: `gone "quiet"`, Device: "nas", Status: "missing", Time: time.Now()}
+	if err := Send(n, ev); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if gotMethod != http.MethodPut {
+		t.Errorf("expected PUT, got %s", gotMethod)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(gotBody), &payload); err != nil {
+		t.Fatalf("body is not valid JSON: %v\n%s", err, gotBody)
+	}
+	if payload["text"] != `gone "quiet"` || payload["device"] != "nas" || payload["status"] != "missing" {
+		t.Errorf("unexpected payload: %v", payload)
+	}
+	if gotHeader.Get("Authorization") != "Bearer abc" || gotHeader.Get("X-Env") != "prod" {
+		t.Errorf("headers not sent: %v", gotHeader)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(gotHeader.Get("X-Timestamp") + "." + gotBody))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); gotHeader.Get("X-Signature") != want {
+		t.Errorf("signature = %q, want %q", gotHeader.Get("X-Signature"), want)
+	}
+}
+
+func TestWebhookNotifierDefaultBody(t *testing.T) {
+	var got Event
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	n := CreateNotifier("webhook", map[string]string{"url": srv.URL})
+	if err := n.Notify("subj", "msg"); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if got.Subject != "subj" || got.Message != "msg" || got.Time.IsZero() {
+		t.Errorf("unexpected default payload: %+v", got)
+	}
+	status = http.StatusBadGateway
+	if err := n.Notify("subj", "msg"); err == nil {
+		t.Error("expected error for non-2xx response")
+	}
+}
+
+func TestWebhookNotifierInvalidConfig(t *testing.T) {
+	for _, props := range []map[string]string{
+		{},
+		{"url": "http://example.com", "headers": "no colon"},
+		{"url": "http://example.com", "body": "{{.Broken"},
+	} {
+		if n := CreateNotifier("webhook", props); n != nil {
+			t.Errorf("expected nil notifier for %v", props)
+		}
+	}
+}
+
+func TestSlackNotifierWebhook(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	n := CreateNotifier("slack", map[string]string{"webhook_url": srv.URL})
+	ev := Event{Type: EventTimeout, Subject: "Triggered", Message: "a <b> & c", Device: "nas", LastSeen: time.Unix(1700000000, 0), Time: time.Now()}
+	if err := Send(n, ev); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	attachment := got["attachments"].([]any)[0].(map[string]any)
+	if attachment["color"] != colorAlert {
+		t.Errorf("expected alert colour, got %v", attachment["color"])
+	}
+	blocks := attachment["blocks"].([]any)
+	section := blocks[1].(map[string]any)["text"].(map[string]any)
+	if section["text"] != "a &lt;b&gt; &amp; c" {
+		t.Errorf("message not escaped: %v", section["text"])
+	}
+	fields := blocks[2].(map[string]any)["fields"].([]any)
+	if fields[0].(map[string]any)["text"] != "*Device*\nnas" {
+		t.Errorf("unexpected device field: %v", fields[0])
+	}
+	if !strings.Contains(fields[1].(map[string]any)["text"].(string), "<!date^1700000000^") {
+		t.Errorf("unexpected last seen field: %v", fields[1])
+	}
+}
+
+func TestSlackNotifierThreadsRecovery(t *testing.T) {
+	var posts []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" || r.Header.Get("Authorization") != "Bearer xoxb-1" {
+			t.Errorf("unexpected request: %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var p map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&p)
+		posts = append(posts, p)
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "ts": fmt.Sprintf("17.%d", len(posts))})
+	}))
+	defer srv.Close()
+
+	n := CreateNotifier("slack", map[string]string{"bot_token": "xoxb-1", "channel": "#ops", "api_url": srv.URL})
+	for _, ev := range []Event{
+		{Type: EventTimeout, Subject: "Triggered", Device: "nas"},
+		{Type: EventFailure, Subject: "Failed", Device: "nas"},
+		{Type: EventRecovery, Subject: "Recovered", Device: "nas"},
+		{Type: EventRecovery, Subject: "Recovered", Device: "nas"},
+	} {
+		if err := Send(n, ev); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	if posts[0]["channel"] != "#ops" || posts[0]["thread_ts"] != nil {
+		t.Errorf("unexpected alert post: %v", posts[0])
+	}
+	if posts[2]["thread_ts"] != "17.1" {
+		t.Errorf("recovery not threaded under first alert: %v", posts[2]["thread_ts"])
+	}
+	if posts[3]["thread_ts"] != nil {
+		t.Errorf("thread should be closed after recovery: %v", posts[3]["thread_ts"])
+	}
+}
+
+func TestSlackNotifierAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"ok":false,"error":"channel_not_found"}`)
+	}))
+	defer srv.Close()
+
+	n := CreateNotifier("slack", map[string]string{"bot_token": "xoxb-1", "channel": "#nope", "api_url": srv.URL})
+	if err := n.Notify("subj", "msg"); err == nil || !strings.Contains(err.Error(), "channel_not_found") {
+		t.Errorf("expected channel_not_found error, got %v", err)
+	}
+	if CreateNotifier("slack", map[string]string{"bot_token": "xoxb-1"}) != nil {
+		t.Error("expected nil notifier without channel")
+	}
+	if CreateNotifier("slack", map[string]string{}) != nil {
+		t.Error("expected nil notifier without webhook_url or bot_token")
+	}
+}
+
+// jsonServer records decoded JSON request bodies and answers with status.
+func jsonServer(t *testing.T, status int) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &bodies
+}
+
+func TestDiscordNotifier(t *testing.T) {
+	srv, bodies := jsonServer(t, http.StatusNoContent)
+	n := CreateNotifier("discord", map[string]string{"webhook_url": srv.URL, "username": "DMS"})
+	now := time.Now()
+	ev := Event{Type: EventTimeout, Subject: "Triggered", Message: "gone", Device: "nas", LastSeen: now.Add(-90 * time.Second), Time: now}
+	if err := Send(n, ev); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	got := (*bodies)[0]
+	if got["username"] != "DMS" {
+		t.Errorf("username not set: %v", got)
+	}
+	embed := got["embeds"].([]any)[0].(map[string]any)
+	if embed["title"] != "Triggered" || embed["description"] != "gone" || embed["color"] != float64(0xe53e3e) {
+		t.Errorf("unexpected embed: %v", embed)
+	}
+	fields := embed["fields"].([]any)
+	if len(fields) != 3 || fields[0].(map[string]any)["value"] != "nas" || fields[1].(map[string]any)["value"] != "1m30s" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+
+	ev.Type, ev.LastSeen = EventRecovery, now
+	if err := Send(n, ev); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	embed = (*bodies)[1]["embeds"].([]any)[0].(map[string]any)
+	if embed["color"] != float64(0x38a169) || len(embed["fields"].([]any)) != 2 {
+		t.Errorf("unexpected recovery embed: %v", embed)
+	}
+	if CreateNotifier("discord", map[string]string{}) != nil {
+		t.Error("expected nil notifier without webhook_url")
+	}
+}
+
+func TestTeamsNotifier(t *testing.T) {
+	srv, bodies := jsonServer(t, http.StatusOK)
+	n := CreateNotifier("teams", map[string]string{"webhook_url": srv.URL})
+	now := time.Now()
+	ev := Event{Type: EventTimeout, Subject: "Triggered", Message: "gone", Device: "nas", LastSeen: now.Add(-time.Hour), Time: now}
+	if err := Send(n, ev); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	attachment := (*bodies)[0]["attachments"].([]any)[0].(map[string]any)
+	if attachment["contentType"] != "application/vnd.microsoft.card.adaptive" {
+		t.Errorf("unexpected content type: %v", attachment["contentType"])
+	}
+	card := attachment["content"].(map[string]any)
+	body := card["body"].([]any)
+	if body[0].(map[string]any)["style"] != "attention" {
+		t.Errorf("expected attention style, got %v", body[0])
+	}
+	facts := body[2].(map[string]any)["facts"].([]any)
+	if facts[0].(map[string]any)["value"] != "nas" || facts[1].(map[string]any)["value"] != "1h0m0s" {
+		t.Errorf("unexpected facts: %v", facts)
+	}
+
+	failing, _ := jsonServer(t, http.StatusBadRequest)
+	if err := CreateNotifier("teams", map[string]string{"webhook_url": failing.URL}).Notify("s", "m"); err == nil {
+		t.Error("expected error for non-2xx response")
+	}
+	if CreateNotifier("teams", map[string]string{}) != nil {
+		t.Error("expected nil notifier without webhook_url")
+	}
+}
+
+func TestNtfyNotifier(t *testing.T) {
+	var auth string
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n := CreateNotifier("ntfy", map[string]string{
+		"server_url": srv.URL + "/", "topic": "dms", "token": "tk_1", "tags": "prod, backup",
+		"click_url": "https://dms.example.com/web/device/{{name}}", "priority_recovery": "2",
+	})
+	if err := Send(n, Event{Type: EventTimeout, Subject: "Triggered", Message: "gone", Device: "nas 1"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if auth != "Bearer tk_1" {
+		t.Errorf("unexpected auth header %q", auth)
+	}
+	if got["topic"] != "dms" || got["priority"] != float64(4) || got["click"] != "https://dms.example.com/web/device/nas%201" {
+		t.Errorf("unexpected payload: %v", got)
+	}
+	if tags, _ := json.Marshal(got["tags"]); string(tags) != `["rotating_light","prod","backup","nas 1"]` {
+		t.Errorf("unexpected tags: %s", tags)
+	}
+	if err := Send(n, Event{Type: EventRecovery, Subject: "Recovered", Device: "nas 1"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got["priority"] != float64(2) {
+		t.Errorf("priority override not applied: %v", got["priority"])
+	}
+
+	for _, props := range []map[string]string{{}, {"topic": "dms", "priority_timeout": "high"}} {
+		if CreateNotifier("ntfy", props) != nil {
+			t.Errorf("expected nil notifier for %v", props)
+		}
+	}
+}
+
+func TestGotifyNotifier(t *testing.T) {
+	var key, path string
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, path = r.Header.Get("X-Gotify-Key"), r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n := CreateNotifier("gotify", map[string]string{"server_url": srv.URL, "app_token": "A1", "click_url": "https://dms.example.com/"})
+	if err := Send(n, Event{Type: EventTimeout, Subject: "Triggered", Message: "gone", Device: "nas"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if key != "A1" || path != "/message" {
+		t.Errorf("unexpected request: key %q path %q", key, path)
+	}
+	if got["priority"] != float64(8) || !strings.HasSuffix(got["title"].(string), " Triggered") {
+		t.Errorf("unexpected payload: %v", got)
+	}
+	click := got["extras"].(map[string]any)["client::notification"].(map[string]any)["click"].(map[string]any)
+	if click["url"] != "https://dms.example.com/" {
+		t.Errorf("unexpected click url: %v", click)
+	}
+	if err := Send(n, Event{Type: EventRecovery, Subject: "Recovered"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got["priority"] != float64(5) {
+		t.Errorf("expected default priority for recovery, got %v", got["priority"])
+	}
+	if CreateNotifier("gotify", map[string]string{"server_url": srv.URL}) != nil {
+		t.Error("expected nil notifier without app_token")
+	}
+}
+
+func TestPushoverNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var messages []url.Values
+	var cancelled, polls int
+	acknowledged := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/messages.json":
+			_ = r.ParseForm()
+			messages = append(messages, r.PostForm)
+			if r.PostForm.Get("priority") == "2" {
+				_, _ = io.WriteString(w, `{"status":1,"receipt":"R1"}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"status":1}`)
+		case r.URL.Path == "/receipts/R1.json":
+			polls++
+			if r.URL.Query().Get("token") != "app" {
+				t.Errorf("receipt polled without token")
+			}
+			fmt.Fprintf(w, `{"status":1,"acknowledged":%d,"acknowledged_at":1700000000,"acknowledged_by":"ukey","acknowledged_by_device":"phone"}`, map[bool]int{false: 0, true: 1}[acknowledged])
+		case r.URL.Path == "/receipts/R1/cancel.json":
+			cancelled++
+			_, _ = io.WriteString(w, `{"status":1}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	acks := make(chan string, 1)
+	OnAcknowledge = func(device, by string, at time.Time) {
+		acks <- device + " " + by + " " + at.UTC().Format(time.RFC3339)
+	}
+	defer func() { OnAcknowledge = nil }()
+
+	p, err := newPushoverNotifier(map[string]string{
+		"api_url": srv.URL, "app_token": "app", "user_key": "ukey", "device": "phone",
+		"priority_timeout": "2", "retry": "30", "expire": "600", "sound": "pushover", "sound_timeout": "siren",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.PollInterval = 10 * time.Millisecond
+	if err := Send(p, Event{Type: EventTimeout, Subject: "Triggered", Message: "gone", Device: "nas", Time: time.Now()}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	mu.Lock()
+	m := messages[0]
+	if m.Get("token") != "app" || m.Get("user") != "ukey" || m.Get("device") != "phone" || m.Get("sound") != "siren" ||
+		m.Get("retry") != "30" || m.Get("expire") != "600" {
+		t.Errorf("unexpected emergency message: %v", m)
+	}
+	acknowledged = true
+	mu.Unlock()
+
+	select {
+	case got := <-acks:
+		if got != "nas phone 2023-11-14T22:13:20Z" {
+			t.Errorf("unexpected acknowledgement %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("acknowledgement was not reported")
+	}
+
+	// Acknowledged receipts are not cancelled on recovery
+	if err := Send(p, Event{Type: EventRecovery, Subject: "Recovered", Device: "nas"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	mu.Lock()
+	if cancelled != 0 || messages[1].Get("priority") != "0" || messages[1].Get("sound") != "pushover" || messages[1].Get("retry") != "" {
+		t.Errorf("unexpected recovery: cancelled %d, message %v", cancelled, messages[1])
+	}
+	acknowledged = false
+	mu.Unlock()
+
+	// An unacknowledged emergency message is cancelled by the recovery
+	p.PollInterval = time.Hour
+	if err := Send(p, Event{Type: EventTimeout, Subject: "Triggered", Device: "nas"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := Send(p, Event{Type: EventRecovery, Subject: "Recovered", Device: "nas"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if cancelled != 1 {
+		t.Errorf("expected the emergency message to be cancelled, got %d cancellations", cancelled)
+	}
+}
+
+func TestPushoverNotifierInvalidConfig(t *testing.T) {
+	for _, props := range []map[string]string{
+		{"app_token": "app"},
+		{"app_token": "app", "user_key": "u", "retry": "10"},
+		{"app_token": "app", "user_key": "u", "expire": "86400"},
+		{"app_token": "app", "user_key": "u", "priority_timeout": "3"},
+	} {
+		if n := CreateNotifier("pushover", props); n != nil {
+			t.Errorf("expected nil notifier for %v", props)
+		}
+	}
+}
+
+func TestMatrixNotifier(t *testing.T) {
+	var paths []string
+	var got map[string]any
+	limited := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer syt_1" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Authorization"))
+		}
+		paths = append(paths, r.URL.EscapedPath())
+		if limited > 0 {
+			limited--
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, `{"errcode":"M_LIMIT_EXCEEDED","retry_after_ms":10}`)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = io.WriteString(w, `{"event_id":"$1"}`)
+	}))
+	defer srv.Close()
+
+	n := CreateNotifier("matrix", map[string]string{"homeserver_url": srv.URL + "/", "access_token": "syt_1", "room_id": "!ops:example.org"})
+	ev := Event{Type: EventTimeout, Subject: "Triggered", Message: "gone <quiet>\nsince noon", Device: "nas", LastSeen: time.Unix(1700000000, 0)}
+	if err := Send(n, ev); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(paths) != 3 || paths[0] != paths[2] || !strings.HasPrefix(paths[0], "/_matrix/client/v3/rooms/%21ops:example.org/send/m.room.message/") {
+		t.Errorf("expected two retries with the same transaction, got %v", paths)
+	}
+	if got["msgtype"] != "m.text" || got["format"] != "org.matrix.custom.html" {
+		t.Errorf("unexpected message: %v", got)
+	}
+	if body := got["body"].(string); !strings.Contains(body, "gone <quiet>") || !strings.Contains(body, "Device: nas") {
+		t.Errorf("unexpected plain body: %q", body)
+	}
+	if html := got["formatted_body"].(string); !strings.Contains(html, "gone &lt;quiet&gt;<br>since noon") || !strings.Contains(html, "2023-11-14T22:13:20Z") {
+		t.Errorf("unexpected formatted body: %q", html)
+	}
+
+	limited = 10
+	if err := n.Notify("subj", "msg"); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("expected rate limit error after retries, got %v", err)
+	}
+	if CreateNotifier("matrix", map[string]string{"homeserver_url": srv.URL}) != nil {
+		t.Error("expected nil notifier without access_token and room_id")
+	}
+}
+
+func TestMatrixNotifierRetryBudget(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, `{"errcode":"M_LIMIT_EXCEEDED","retry_after_ms":60000}`)
+	}))
+	defer srv.Close()
+
+	n := CreateNotifier("matrix", map[string]string{"homeserver_url": srv.URL, "access_token": "syt_1", "room_id": "!ops:example.org"})
+	start := time.Now()
+	err := n.Notify("subj", "msg")
+	if err == nil || !strings.Contains(err.Error(), "giving up") {
+		t.Errorf("expected to give up on a long rate limit, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > matrixRetryBudget {
+		t.Errorf("Notify blocked for %s, budget is %s", elapsed, matrixRetryBudget)
+	}
+	if requests != 1 {
+		t.Errorf("expected no retry beyond the budget, got %d requests", requests)
+	}
+}
+
+func TestPagerDutyNotifier(t *testing.T) {
+	srv, bodies := jsonServer(t, http.StatusAccepted)
+	n := CreateNotifier("pagerduty", map[string]string{
+		"api_url": srv.URL, "routing_key": "R1", "client_url": "https://dms.example.com/web/device/{{name}}", "severity_failure": "Warning",
+	})
+	now := time.Now()
+	for _, ev := range []Event{
+		{Type: EventTimeout, Subject: "Triggered", Message: "gone", Device: "nas", Status: "missing", LastSeen: now.Add(-time.Hour), Time: now},
+		{Type: EventFailure, Subject: "Failed", Message: "disk full", Device: "nas"},
+		{Type: EventRecovery, Subject: "Recovered", Device: "nas"},
+		{Type: EventExpiry, Subject: "Expiring", Message: "in 3 days", Device: "web-cert"},
+	} {
+		if err := Send(n, ev); err != nil {
+			t.Fatalf("send %s: %v", ev.Type, err)
+		}
+	}
+	trigger := (*bodies)[0]
+	if trigger["routing_key"] != "R1" || trigger["event_action"] != "trigger" || trigger["dedup_key"] != "dead-mans-switch/nas" ||
+		trigger["client_url"] != "https://dms.example.com/web/device/nas" {
+		t.Errorf("unexpected trigger: %v", trigger)
+	}
+	payload := trigger["payload"].(map[string]any)
+	if payload["summary"] != "Triggered: gone" || payload["source"] != "nas" || payload["severity"] != "critical" {
+		t.Errorf("unexpected trigger payload: %v", payload)
+	}
+	if severity := (*bodies)[1]["payload"].(map[string]any)["severity"]; severity != "warning" {
+		t.Errorf("severity override not applied: %v", severity)
+	}
+	resolve := (*bodies)[2]
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != "dead-mans-switch/nas" || resolve["payload"] != nil {
+		t.Errorf("unexpected resolve: %v", resolve)
+	}
+	if key := (*bodies)[3]["dedup_key"]; key != "dead-mans-switch/web-cert/expiry" {
+		t.Errorf("unexpected expiry dedup key: %v", key)
+	}
+
+	for _, props := range []map[string]string{{}, {"routing_key": "R1", "severity_timeout": "panic"}} {
+		if CreateNotifier("pagerduty", props) != nil {
+			t.Errorf("expected nil notifier for %v", props)
+		}
+	}
+}
+
+func TestOpsgenieNotifier(t *testing.T) {
+	type request struct {
+		uri  string
+		auth string
+		body map[string]any
+	}
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, request{r.URL.RequestURI(), r.Header.Get("Authorization"), body})
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	n := CreateNotifier("opsgenie", map[string]string{
+		"api_url": srv.URL, "api_key": "k1", "responders": "team:Ops, user:alice@example.com", "tags": "prod,dms", "priority_timeout": "p2",
+	})
+	if err := Send(n, Event{Type: EventTimeout, Subject: "Triggered", Message: "gone", Device: "nas", Status: "missing"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := Send(n, Event{Type: EventRecovery, Subject: "Recovered", Message: "back", Device: "nas"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	create, closeReq := requests[0], requests[1]
+	if create.uri != "/v2/alerts" || create.auth != "GenieKey k1" {
+		t.Errorf("unexpected create request: %s %s", create.uri, create.auth)
+	}
+	if create.body["alias"] != "dead-mans-switch-nas" || create.body["priority"] != "P2" || create.body["message"] != "Triggered: nas" {
+		t.Errorf("unexpected alert: %v", create.body)
+	}
+	if responders, _ := json.Marshal(create.body["responders"]); string(responders) != `[{"name":"Ops","type":"team"},{"type":"user","username":"alice@example.com"}]` {
+		t.Errorf("unexpected responders: %s", responders)
+	}
+	if tags, _ := json.Marshal(create.body["tags"]); string(tags) != `["prod","dms"]` {
+		t.Errorf("unexpected tags: %s", tags)
+	}
+	if closeReq.uri != "/v2/alerts/dead-mans-switch-nas/close?identifierType=alias" || closeReq.body["note"] != "back" {
+		t.Errorf("unexpected close request: %s %v", closeReq.uri, closeReq.body)
+	}
+
+	for _, props := range []map[string]string{
+		{},
+		{"api_key": "k1", "responders": "Ops"},
+		{"api_key": "k1", "responders": "group:Ops"},
+		{"api_key": "k1", "priority_failure": "high"},
+	} {
+		if CreateNotifier("opsgenie", props) != nil {
+			t.Errorf("expected nil notifier for %v", props)
+		}
+	}
+}
+
+func TestExecNotifier(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	n := CreateNotifier("exec", map[string]string{
+		"command": "sh",
+		"args":    "-c\n" + `echo "$DMS_EVENT_TYPE $DMS_DEVICE $DMS_STATUS" > "$1"; cat >> "$1"; echo oops >&2; exit 3` + "\nnotify\n" + out,
+	})
+	x := n.(*ExecNotifier)
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	ev := Event{Type: EventTimeout, Subject: "Triggered", Message: "gone", Device: "nas", Status: "missing"}
+	if err := x.run(ev); err == nil || err.Error() != "exit status 3" {
+		t.Errorf("expected exit status 3, got %v", err)
+	}
+	got, _ := os.ReadFile(out)
+	env, stdin, _ := strings.Cut(string(got), "\n")
+	if env != "timeout nas missing" {
+		t.Errorf("unexpected environment: %q", env)
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(stdin), &e); err != nil || e.Message != "gone" {
+		t.Errorf("unexpected stdin %q: %v", stdin, err)
+	}
+	if !strings.Contains(logs.String(), "exit status 3; stderr: oops") {
+		t.Errorf("exit status and stderr not logged: %s", logs.String())
+	}
+
+	x.Args, x.Timeout = []string{"-c", "sleep 5"}, 50*time.Millisecond
+	start := time.Now()
+	if err := x.run(ev); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("timeout not enforced, took %s", time.Since(start))
+	}
+}
+
+func TestExecNotifierDoesNotBlock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	n := CreateNotifier("exec", map[string]string{"command": "sh", "args": "-c\nsleep 0.2; touch \"$0\"\n" + out})
+	start := time.Now()
+	if err := n.Notify("subj", "msg"); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	if time.Since(start) > 150*time.Millisecond {
+		t.Errorf("Notify blocked for %s", time.Since(start))
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if _, err := os.Stat(out); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("command did not run in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, props := range []map[string]string{{}, {"command": "true", "timeout_seconds": "0"}} {
+		if CreateNotifier("exec", props) != nil {
+			t.Errorf("expected nil notifier for %v", props)
+		}
+	}
+}
+
+func TestExecNotifierLimitsConcurrency(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	started, release := filepath.Join(dir, "started"), filepath.Join(dir, "release")
+	n := CreateNotifier("exec", map[string]string{
+		"command": "sh",
+		"args":    "-c\n" + `echo x >> "$0"; while [ ! -e "$1" ]; do sleep 0.01; done` + "\n" + started + "\n" + release,
+	})
+	count := func() int {
+		data, _ := os.ReadFile(started)
+		return strings.Count(string(data), "x")
+	}
+	waitCount := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for count() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d started commands, got %d", want, count())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	for range execWorkers {
+		if err := n.Notify("subj", "msg"); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+	}
+	waitCount(execWorkers)
+	for range execQueueSize {
+		if err := n.Notify("subj", "msg"); err != nil {
+			t.Fatalf("notify with free queue slots: %v", err)
+		}
+	}
+	start := time.Now()
+	if err := n.Notify("subj", "msg"); err == nil || !strings.Contains(err.Error(), "dropping") {
+		t.Errorf("expected full queue to drop the notification, got %v", err)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Errorf("Notify blocked on a full queue for %s", time.Since(start))
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := count(); got != execWorkers {
+		t.Errorf("expected at most %d concurrent commands, got %d", execWorkers, got)
+	}
+
+	if err := os.WriteFile(release, nil, 0o600); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	waitCount(execWorkers + execQueueSize)
+}
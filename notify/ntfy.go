@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ntfyTags are emoji shortcodes shown in front of the title per event type.
+var ntfyTags = map[string]string{
+	EventTimeout:  "rotating_light",
+	EventFailure:  "x",
+	EventRecovery: "white_check_mark",
+	EventExpiry:   "warning",
+}
+
+// NtfyNotifier publishes to an ntfy topic.
+type NtfyNotifier struct {
+	ServerURL  string
+	Topic      string
+	Token      string
+	Username   string
+	Password   string
+	Tags       []string
+	Click      string
+	priorities map[string]int
+}
+
+func (n *NtfyNotifier) Notify(subject, message string) error {
+	return n.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+func (n *NtfyNotifier) NotifyEvent(e Event) error {
+	payload := map[string]any{
+		"topic":   n.Topic,
+		"title":   e.Subject,
+		"message": e.Message,
+	}
+	if p, ok := n.priorities[e.Type]; ok {
+		payload["priority"] = p
+	}
+	tags := append([]string(nil), n.Tags...)
+	if tag, ok := ntfyTags[e.Type]; ok {
+		tags = append([]string{tag}, tags...)
+	}
+	if e.Device != "" {
+		tags = append(tags, e.Device)
+	}
+	if len(tags) > 0 {
+		payload["tags"] = tags
+	}
+	if n.Click != "" {
+		payload["click"] = clickURL(n.Click, e)
+	}
+	header := http.Header{}
+	switch {
+	case n.Token != "":
+		header.Set("Authorization", "Bearer "+n.Token)
+	case n.Username != "":
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(n.Username+":"+n.Password)))
+	}
+	if err := postJSON(n.ServerURL, header, payload, nil); err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	return nil
+}
+
+func NewNtfyNotifier(props map[string]string) Notifier {
+	n := &NtfyNotifier{
+		ServerURL: strings.TrimSuffix(props["server_url"], "/"),
+		Topic:     props["topic"],
+		Token:     props["token"],
+		Username:  props["username"],
+		Password:  props["password"],
+		Tags:      splitList(props["tags"]),
+		Click:     props["click_url"],
+	}
+	if n.ServerURL == "" {
+		n.ServerURL = "https://ntfy.sh"
+	}
+	var err error
+	if n.Topic == "" {
+		err = errors.New("topic is required")
+	} else {
+		// ntfy priorities: 1 min, 3 default, 4 high, 5 urgent
+		n.priorities, err = eventPriorities(props, map[string]int{
+			EventTimeout:  4,
+			EventFailure:  4,
+			EventExpiry:   3,
+			EventRecovery: 3,
+		})
+	}
+	if err != nil {
+		log.Printf("ntfy notifier: %v", err)
+		return nil
+	}
+	return n
+}
+
+func init() {
+	Register("ntfy", NewNtfyNotifier)
+}
@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Opsgenie field limits
+const (
+	opsgenieMaxMessage     = 130
+	opsgenieMaxDescription = 15000
+)
+
+// opsgeniePriority matches the priorities Opsgenie accepts.
+var opsgeniePriority = regexp.MustCompile(`^P[1-5]$`)
+
+// OpsgenieNotifier creates Opsgenie alerts with one alias per device and closes
+// the alert when the device recovers, mirroring the missing state of the device.
+type OpsgenieNotifier struct {
+	APIURL     string
+	APIKey     string
+	Responders []map[string]string
+	Tags       []string
+	priorities map[string]string
+}
+
+func (o *OpsgenieNotifier) Notify(subject, message string) error {
+	return o.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+func (o *OpsgenieNotifier) NotifyEvent(e Event) error {
+	header := http.Header{"Authorization": {"GenieKey " + o.APIKey}}
+	if e.Type == EventRecovery {
+		if e.Device == "" {
+			return nil
+		}
+		u := o.APIURL + "/v2/alerts/" + url.PathEscape(opsgenieAlias(e)) + "/close?identifierType=alias"
+		body := map[string]any{"source": "dead-mans-switch", "note": e.Message}
+		if err := postJSON(u, header, body, nil); err != nil {
+			return fmt.Errorf("opsgenie: close alert for %s: %w", e.Device, err)
+		}
+		return nil
+	}
+	alert := map[string]any{
+		"message":     truncate(e.Subject+": "+e.Device, opsgenieMaxMessage),
+		"description": truncate(e.Message, opsgenieMaxDescription),
+		"source":      "dead-mans-switch",
+	}
+	if e.Device == "" {
+		alert["message"] = truncate(e.Subject, opsgenieMaxMessage)
+	} else {
+		alert["alias"] = opsgenieAlias(e)
+		alert["entity"] = e.Device
+	}
+	if p, ok := o.priorities[e.Type]; ok {
+		alert["priority"] = p
+	}
+	if len(o.Responders) > 0 {
+		alert["responders"] = o.Responders
+	}
+	if len(o.Tags) > 0 {
+		alert["tags"] = o.Tags
+	}
+	details := map[string]string{}
+	if e.Status != "" {
+		details["status"] = e.Status
+	}
+	if !e.LastSeen.IsZero() {
+		details["last_seen"] = e.LastSeen.UTC().Format(time.RFC3339)
+	}
+	if len(details) > 0 {
+		alert["details"] = details
+	}
+	if err := postJSON(o.APIURL+"/v2/alerts", header, alert, nil); err != nil {
+		return fmt.Errorf("opsgenie: %w", err)
+	}
+	return nil
+}
+
+// opsgenieAlias is stable per device, so repeated alerts are deduplicated and a
+// recovery closes them. Certificate expiry warnings get their own alias, as a
+// recovery of the device does not renew its certificate.
+func opsgenieAlias(e Event) string {
+	if e.Type == EventExpiry {
+		return "dead-mans-switch-" + e.Device + "-expiry"
+	}
+	return "dead-mans-switch-" + e.Device
+}
+
+// parseResponders parses a comma-separated list of "type:name" responders,
+// e.g. "team:Ops, user:alice@example.com".
+func parseResponders(s string) ([]map[string]string, error) {
+	var responders []map[string]string
+	for _, item := range splitList(s) {
+		kind, name, ok := strings.Cut(item, ":")
+		kind, name = strings.TrimSpace(kind), strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid responder %q, expected type:name", item)
+		}
+		switch kind {
+		case "team", "escalation", "schedule":
+			responders = append(responders, map[string]string{"type": kind, "name": name})
+		case "user":
+			responders = append(responders, map[string]string{"type": kind, "username": name})
+		default:
+			return nil, fmt.Errorf("invalid responder type %q, expected team, user, escalation or schedule", kind)
+		}
+	}
+	return responders, nil
+}
+
+func newOpsgenieNotifier(props map[string]string) (*OpsgenieNotifier, error) {
+	o := &OpsgenieNotifier{
+		APIURL: strings.TrimSuffix(props["api_url"], "/"),
+		APIKey: props["api_key"],
+		Tags:   splitList(props["tags"]),
+		priorities: map[string]string{
+			EventTimeout: "P1",
+			EventFailure: "P2",
+			EventExpiry:  "P3",
+		},
+	}
+	if o.APIURL == "" {
+		o.APIURL = "https://api.opsgenie.com"
+	}
+	if o.APIKey == "" {
+		return nil, errors.New("api_key is required")
+	}
+	var err error
+	if o.Responders, err = parseResponders(props["responders"]); err != nil {
+		return nil, err
+	}
+	for t := range o.priorities {
+		if v := strings.ToUpper(props["priority_"+t]); v != "" {
+			if !opsgeniePriority.MatchString(v) {
+				return nil, fmt.Errorf("priority_%s must be P1 to P5", t)
+			}
+			o.priorities[t] = v
+		}
+	}
+	return o, nil
+}
+
+func NewOpsgenieNotifier(props map[string]string) Notifier {
+	o, err := newOpsgenieNotifier(props)
+	if err != nil {
+		log.Printf("opsgenie notifier: %v", err)
+		return nil
+	}
+	return o
+}
+
+func init() {
+	Register("opsgenie", NewOpsgenieNotifier)
+}
@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// pagerDutyMaxSummary is the longest summary the Events API accepts.
+const pagerDutyMaxSummary = 1024
+
+// PagerDutyNotifier sends PagerDuty Events API v2 events. Alerts for a device
+// share a dedup key, so a recovery resolves the incident its timeout or
+// failure triggered.
+type PagerDutyNotifier struct {
+	APIURL     string
+	RoutingKey string
+	ClientURL  string
+	severities map[string]string
+}
+
+func (p *PagerDutyNotifier) Notify(subject, message string) error {
+	return p.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+func (p *PagerDutyNotifier) NotifyEvent(e Event) error {
+	event := map[string]any{"routing_key": p.RoutingKey}
+	if e.Type == EventRecovery {
+		if e.Device == "" {
+			return nil
+		}
+		event["event_action"] = "resolve"
+		event["dedup_key"] = pagerDutyDedupKey(e)
+	} else {
+		event["event_action"] = "trigger"
+		if e.Device != "" {
+			event["dedup_key"] = pagerDutyDedupKey(e)
+		}
+		event["payload"] = p.payload(e)
+		event["client"] = "Dead Man's Switch"
+		if p.ClientURL != "" {
+			event["client_url"] = clickURL(p.ClientURL, e)
+		}
+	}
+	if err := postJSON(p.APIURL, nil, event, nil); err != nil {
+		return fmt.Errorf("pagerduty: %w", err)
+	}
+	return nil
+}
+
+// pagerDutyDedupKey is stable per device. Certificate expiry warnings get their
+// own key, as a recovery of the device does not renew its certificate.
+func pagerDutyDedupKey(e Event) string {
+	if e.Type == EventExpiry {
+		return "dead-mans-switch/" + e.Device + "/expiry"
+	}
+	return "dead-mans-switch/" + e.Device
+}
+
+func (p *PagerDutyNotifier) payload(e Event) map[string]any {
+	severity, ok := p.severities[e.Type]
+	if !ok {
+		severity = "error"
+	}
+	source := e.Device
+	if source == "" {
+		source = "dead-mans-switch"
+	}
+	details := map[string]any{"message": e.Message}
+	if e.Status != "" {
+		details["status"] = e.Status
+	}
+	if !e.LastSeen.IsZero() {
+		details["last_seen"] = e.LastSeen.UTC().Format(time.RFC3339)
+	}
+	payload := map[string]any{
+		"summary":        truncate(e.Subject+": "+e.Message, pagerDutyMaxSummary),
+		"source":         source,
+		"severity":       severity,
+		"custom_details": details,
+	}
+	if e.Type != "" {
+		payload["class"] = e.Type
+	}
+	if !e.Time.IsZero() {
+		payload["timestamp"] = e.Time.UTC().Format(time.RFC3339)
+	}
+	return payload
+}
+
+// pagerDutySeverities are the severities the Events API accepts.
+var pagerDutySeverities = map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+
+func NewPagerDutyNotifier(props map[string]string) Notifier {
+	p := &PagerDutyNotifier{
+		APIURL:     props["api_url"],
+		RoutingKey: props["routing_key"],
+		ClientURL:  props["client_url"],
+		severities: map[string]string{
+			EventTimeout: "critical",
+			EventFailure: "error",
+			EventExpiry:  "warning",
+		},
+	}
+	if p.APIURL == "" {
+		p.APIURL = "https://events.pagerduty.com/v2/enqueue"
+	}
+	if p.RoutingKey == "" {
+		log.Printf("pagerduty notifier: routing_key is required")
+		return nil
+	}
+	for t := range p.severities {
+		if v := strings.ToLower(props["severity_"+t]); v != "" {
+			if !pagerDutySeverities[v] {
+				log.Printf("pagerduty notifier: severity_%s must be critical, error, warning or info", t)
+				return nil
+			}
+			p.severities[t] = v
+		}
+	}
+	return p
+}
+
+func init() {
+	Register("pagerduty", NewPagerDutyNotifier)
+}
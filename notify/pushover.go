@@ -0,0 +1,234 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pushover priorities and limits
+const (
+	pushoverEmergency  = 2
+	pushoverMinRetry   = 30
+	pushoverMaxExpire  = 10800
+	pushoverMaxTitle   = 250
+	pushoverMaxMessage = 1024
+)
+
+// PushoverNotifier sends Pushover messages. Emergency-priority messages repeat
+// every Retry seconds until acknowledged or Expire seconds have passed; their
+// receipt is polled and an acknowledgement is reported via OnAcknowledge. A
+// recovery cancels the device's outstanding emergency message.
+type PushoverNotifier struct {
+	APIURL       string
+	AppToken     string
+	UserKey      string
+	Device       string
+	Retry        int
+	Expire       int
+	PollInterval time.Duration
+	priorities   map[string]int
+	sounds       map[string]string
+
+	mu       sync.Mutex
+	receipts map[string]string // device name -> receipt of its unacknowledged emergency message
+}
+
+type pushoverResponse struct {
+	Status  int    `json:"status"`
+	Receipt string `json:"receipt"`
+}
+
+func (p *PushoverNotifier) Notify(subject, message string) error {
+	return p.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+func (p *PushoverNotifier) NotifyEvent(e Event) error {
+	var errs []error
+	if e.Type == EventRecovery {
+		errs = append(errs, p.cancel(e.Device))
+	}
+	priority := p.priorities[e.Type]
+	form := url.Values{
+		"token":    {p.AppToken},
+		"user":     {p.UserKey},
+		"title":    {truncate(e.Subject, pushoverMaxTitle)},
+		"message":  {truncate(e.Message, pushoverMaxMessage)},
+		"priority": {strconv.Itoa(priority)},
+	}
+	if p.Device != "" {
+		form.Set("device", p.Device)
+	}
+	if sound, ok := p.sounds[e.Type]; ok {
+		form.Set("sound", sound)
+	} else if sound := p.sounds[""]; sound != "" {
+		form.Set("sound", sound)
+	}
+	if !e.Time.IsZero() {
+		form.Set("timestamp", strconv.FormatInt(e.Time.Unix(), 10))
+	}
+	if priority == pushoverEmergency {
+		form.Set("retry", strconv.Itoa(p.Retry))
+		form.Set("expire", strconv.Itoa(p.Expire))
+	}
+	var resp pushoverResponse
+	if err := postForm(p.APIURL+"/messages.json", form, &resp); err != nil {
+		errs = append(errs, fmt.Errorf("pushover: %w", err))
+	} else if resp.Receipt != "" && e.Device != "" {
+		p.mu.Lock()
+		p.receipts[e.Device] = resp.Receipt
+		p.mu.Unlock()
+		go p.pollReceipt(e.Device, resp.Receipt)
+	}
+	return errors.Join(errs...)
+}
+
+// cancel stops the retries of the device's outstanding emergency message.
+func (p *PushoverNotifier) cancel(device string) error {
+	p.mu.Lock()
+	receipt, ok := p.receipts[device]
+	delete(p.receipts, device)
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	form := url.Values{"token": {p.AppToken}}
+	if err := postForm(p.APIURL+"/receipts/"+url.PathEscape(receipt)+"/cancel.json", form, nil); err != nil {
+		return fmt.Errorf("pushover: cancel emergency message for %s: %w", device, err)
+	}
+	return nil
+}
+
+// current reports whether receipt is still the outstanding one for device.
+func (p *PushoverNotifier) current(device, receipt string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.receipts[device] == receipt
+}
+
+// pollReceipt checks the receipt until it is acknowledged, expires, or is
+// cancelled or replaced by a newer emergency message for the same device.
+func (p *PushoverNotifier) pollReceipt(device, receipt string) {
+	deadline := time.Now().Add(time.Duration(p.Expire)*time.Second + p.PollInterval)
+	u := p.APIURL + "/receipts/" + url.PathEscape(receipt) + ".json?token=" + url.QueryEscape(p.AppToken)
+	ticker := time.NewTicker(p.PollInterval)
+	defer ticker.Stop()
+	for time.Now().Before(deadline) {
+		<-ticker.C
+		if !p.current(device, receipt) {
+			return
+		}
+		var r struct {
+			Acknowledged         int    `json:"acknowledged"`
+			AcknowledgedAt       int64  `json:"acknowledged_at"`
+			AcknowledgedBy       string `json:"acknowledged_by"`
+			AcknowledgedByDevice string `json:"acknowledged_by_device"`
+			Expired              int    `json:"expired"`
+		}
+		if err := getJSON(u, &r); err != nil {
+			log.Printf("pushover: poll receipt for %s: %v", device, err)
+			continue
+		}
+		switch {
+		case r.Acknowledged == 1:
+			by := r.AcknowledgedByDevice
+			if by == "" {
+				by = r.AcknowledgedBy
+			}
+			p.forget(device, receipt)
+			acknowledge(device, by, time.Unix(r.AcknowledgedAt, 0))
+			return
+		case r.Expired == 1:
+			log.Printf("pushover: emergency message for %s expired unacknowledged", device)
+			p.forget(device, receipt)
+			return
+		}
+	}
+	p.forget(device, receipt)
+}
+
+// forget drops receipt if it is still the outstanding one for device.
+func (p *PushoverNotifier) forget(device, receipt string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.receipts[device] == receipt {
+		delete(p.receipts, device)
+	}
+}
+
+// intProp parses an optional integer property.
+func intProp(props map[string]string, key string, def int) (int, error) {
+	v := props[key]
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return n, nil
+}
+
+func newPushoverNotifier(props map[string]string) (*PushoverNotifier, error) {
+	p := &PushoverNotifier{
+		APIURL:       strings.TrimSuffix(props["api_url"], "/"),
+		AppToken:     props["app_token"],
+		UserKey:      props["user_key"],
+		Device:       props["device"],
+		PollInterval: 30 * time.Second,
+		sounds:       map[string]string{"": props["sound"]},
+		receipts:     map[string]string{},
+	}
+	if p.APIURL == "" {
+		p.APIURL = "https://api.pushover.net/1"
+	}
+	if p.AppToken == "" || p.UserKey == "" {
+		return nil, errors.New("app_token and user_key are required")
+	}
+	var err error
+	if p.Retry, err = intProp(props, "retry", 60); err != nil {
+		return nil, err
+	}
+	if p.Expire, err = intProp(props, "expire", 3600); err != nil {
+		return nil, err
+	}
+	if p.Retry < pushoverMinRetry || p.Expire < 1 || p.Expire > pushoverMaxExpire {
+		return nil, fmt.Errorf("retry must be at least %d and expire at most %d seconds", pushoverMinRetry, pushoverMaxExpire)
+	}
+	p.priorities, err = eventPriorities(props, map[string]int{
+		EventTimeout:  1,
+		EventFailure:  1,
+		EventExpiry:   0,
+		EventRecovery: 0,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for t, priority := range p.priorities {
+		if priority < -2 || priority > pushoverEmergency {
+			return nil, fmt.Errorf("priority_%s must be between -2 and 2", t)
+		}
+		if sound := props["sound_"+t]; sound != "" {
+			p.sounds[t] = sound
+		}
+	}
+	return p, nil
+}
+
+func NewPushoverNotifier(props map[string]string) Notifier {
+	p, err := newPushoverNotifier(props)
+	if err != nil {
+		log.Printf("pushover notifier: %v", err)
+		return nil
+	}
+	return p
+}
+
+func init() {
+	Register("pushover", NewPushoverNotifier)
+}
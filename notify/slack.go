@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlackNotifier posts Block Kit messages to an incoming webhook or, with a bot
+// token, via chat.postMessage. In bot mode a recovery is threaded under the
+// alert that opened it.
+type SlackNotifier struct {
+	WebhookURL string
+	BotToken   string
+	Channel    string
+	APIURL     string
+
+	mu      sync.Mutex
+	threads map[string]string // device name -> ts of its open alert
+}
+
+func (s *SlackNotifier) Notify(subject, message string) error {
+	return s.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+func (s *SlackNotifier) NotifyEvent(e Event) error {
+	payload := slackPayload(e)
+	if s.BotToken == "" {
+		return postJSON(s.WebhookURL, nil, payload, nil)
+	}
+
+	payload["channel"] = s.Channel
+	s.mu.Lock()
+	thread, open := s.threads[e.Device]
+	s.mu.Unlock()
+	if e.Type == EventRecovery && open {
+		payload["thread_ts"] = thread
+		payload["reply_broadcast"] = true
+	}
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	header := http.Header{"Authorization": {"Bearer " + s.BotToken}}
+	if err := postJSON(s.APIURL+"/chat.postMessage", header, payload, &resp); err != nil {
+		return err
+	}
+	if !resp.OK {
+		return fmt.Errorf("slack: %s", resp.Error)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e.Type {
+	case EventTimeout, EventFailure:
+		if !open && e.Device != "" {
+			s.threads[e.Device] = resp.TS
+		}
+	case EventRecovery:
+		delete(s.threads, e.Device)
+	}
+	return nil
+}
+
+// slackEscape escapes the characters Slack treats as markup in text objects.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// slackPayload formats e as a coloured attachment with Block Kit blocks; text is
+// the fallback shown in push notifications.
+func slackPayload(e Event) map[string]any {
+	blocks := []any{
+		map[string]any{
+			"type": "header",
+			"text": map[string]any{"type": "plain_text", "text": e.Subject},
+		},
+		map[string]any{
+			"type": "section",
+			"text": map[string]any{"type": "mrkdwn", "text": slackEscape(e.Message)},
+		},
+	}
+	if e.Device != "" {
+		fields := []any{
+			map[string]any{"type": "mrkdwn", "text": "*Device*\n" + slackEscape(e.Device)},
+		}
+		if !e.LastSeen.IsZero() {
+			fields = append(fields, map[string]any{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("*Last seen*\n<!date^%d^{date_short_pretty} {time}|%s>", e.LastSeen.Unix(), e.LastSeen.UTC().Format(time.RFC3339)),
+			})
+		}
+		blocks = append(blocks, map[string]any{"type": "section", "fields": fields})
+	}
+	return map[string]any{
+		"text": e.Subject + ": " + e.Message,
+		"attachments": []any{
+			map[string]any{"color": eventColor(e.Type), "blocks": blocks},
+		},
+	}
+}
+
+func NewSlackNotifier(props map[string]string) Notifier {
+	s := &SlackNotifier{
+		WebhookURL: props["webhook_url"],
+		BotToken:   props["bot_token"],
+		Channel:    props["channel"],
+		APIURL:     strings.TrimSuffix(props["api_url"], "/"),
+		threads:    map[string]string{},
+	}
+	if s.APIURL == "" {
+		s.APIURL = "https://slack.com/api"
+	}
+	var err error
+	switch {
+	case s.BotToken != "" && s.Channel == "":
+		err = errors.New("channel is required with bot_token")
+	case s.BotToken == "" && s.WebhookURL == "":
+		err = errors.New("webhook_url or bot_token is required")
+	}
+	if err != nil {
+		log.Printf("slack notifier: %v", err)
+		return nil
+	}
+	return s
+}
+
+func init() {
+	Register("slack", NewSlackNotifier)
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"log"
+	"time"
+)
+
+// TeamsNotifier posts Adaptive Cards to a Microsoft Teams incoming webhook or
+// Workflows webhook URL.
+type TeamsNotifier struct {
+	WebhookURL string
+}
+
+func (t *TeamsNotifier) Notify(subject, message string) error {
+	return t.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+func (t *TeamsNotifier) NotifyEvent(e Event) error {
+	payload := map[string]any{
+		"type": "message",
+		"attachments": []any{map[string]any{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     teamsCard(e),
+		}},
+	}
+	return postJSON(t.WebhookURL, nil, payload, nil)
+}
+
+// teamsStyle maps an event type to the Adaptive Card container style and text
+// colour; cards only support named colours.
+func teamsStyle(eventType string) (style, color string) {
+	switch eventType {
+	case EventTimeout, EventFailure:
+		return "attention", "Attention"
+	case EventRecovery:
+		return "good", "Good"
+	case EventExpiry:
+		return "warning", "Warning"
+	}
+	return "emphasis", "Default"
+}
+
+// teamsCard formats e as Adaptive Card with a facts table for the device, how
+// long it has been silent and when it was last seen.
+func teamsCard(e Event) map[string]any {
+	style, color := teamsStyle(e.Type)
+	var facts []any
+	fact := func(title, value string) {
+		facts = append(facts, map[string]any{"title": title, "value": value})
+	}
+	if e.Device != "" {
+		fact("Device", e.Device)
+	}
+	if d := silence(e); d > 0 {
+		fact("Duration", d.String())
+	}
+	if !e.LastSeen.IsZero() {
+		fact("Last seen", e.LastSeen.UTC().Format(time.RFC3339))
+	}
+	if !e.Time.IsZero() {
+		fact("Time", e.Time.UTC().Format(time.RFC3339))
+	}
+	body := []any{
+		map[string]any{
+			"type":  "Container",
+			"style": style,
+			"bleed": true,
+			"items": []any{map[string]any{
+				"type": "TextBlock", "text": e.Subject, "weight": "Bolder", "size": "Medium", "color": color, "wrap": true,
+			}},
+		},
+		map[string]any{"type": "TextBlock", "text": e.Message, "wrap": true},
+	}
+	if facts != nil {
+		body = append(body, map[string]any{"type": "FactSet", "facts": facts})
+	}
+	return map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"msteams": map[string]any{"width": "Full"},
+		"body":    body,
+	}
+}
+
+func NewTeamsNotifier(props map[string]string) Notifier {
+	if props["webhook_url"] == "" {
+		log.Printf("teams notifier: webhook_url is required")
+		return nil
+	}
+	return &TeamsNotifier{WebhookURL: props["webhook_url"]}
+}
+
+func init() {
+	Register("teams", NewTeamsNotifier)
+}
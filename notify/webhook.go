@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// WebhookNotifier sends notifications as HTTP requests with a templated body.
+type WebhookNotifier struct {
+	URL         string
+	Method      string
+	Headers     http.Header
+	ContentType string
+	Secret      string
+	body        *template.Template
+}
+
+func (w *WebhookNotifier) Notify(subject, message string) error {
+	return w.NotifyEvent(Event{Subject: subject, Message: message, Time: time.Now()})
+}
+
+// NotifyEvent renders the body template with e and sends it. With a secret, the
+// body is signed like incoming heartbeats: X-Signature carries the hex HMAC-SHA256
+// of "<X-Timestamp>.<body>".
+func (w *WebhookNotifier) NotifyEvent(e Event) error {
+	var body bytes.Buffer
+	if w.body != nil {
+		if err := w.body.Execute(&body, e); err != nil {
+			return fmt.Errorf("webhook body template: %w", err)
+		}
+	} else if err := json.NewEncoder(&body).Encode(e); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(w.Method, w.URL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return err
+	}
+	for k, v := range w.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", w.ContentType)
+	if w.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write([]byte(ts + "."))
+		mac.Write(body.Bytes())
+		req.Header.Set("X-Timestamp", ts)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned status %d", w.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+// parseHeaders parses "Name: value" lines.
+func parseHeaders(s string) (http.Header, error) {
+	h := http.Header{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid header line %q", line)
+		}
+		h.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	return h, nil
+}
+
+// webhookFuncs are available in body templates; {{json .Message}} yields a
+// quoted and escaped JSON string.
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func NewWebhookNotifier(props map[string]string) Notifier {
+	if props["url"] == "" {
+		log.Printf("webhook notifier: url is required")
+		return nil
+	}
+	headers, err := parseHeaders(props["headers"])
+	if err != nil {
+		log.Printf("webhook notifier: %v", err)
+		return nil
+	}
+	w := &WebhookNotifier{
+		URL:         props["url"],
+		Method:      strings.ToUpper(props["method"]),
+		Headers:     headers,
+		ContentType: props["content_type"],
+		Secret:      props["secret"],
+	}
+	if w.Method == "" {
+		w.Method = http.MethodPost
+	}
+	if w.ContentType == "" {
+		w.ContentType = "application/json"
+	}
+	if props["body"] != "" {
+		if w.body, err = template.New("body").Funcs(webhookFuncs).Parse(props["body"]); err != nil {
+			log.Printf("webhook notifier: body template: %v", err)
+			return nil
+		}
+	}
+	return w
+}
+
+func init() {
+	Register("webhook", NewWebhookNotifier)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// maxPingMessage caps the message shown for a device, taken from a ping body.
+const maxPingMessage = 200
+
+// pingHandler serves healthchecks.io-compatible ping URLs, so existing cron
+// scripts only need a new base URL:
+//
+//	/ping/{uuid}              success
+//	/ping/{uuid}/start        job started
+//	/ping/{uuid}/fail         failure
+//	/ping/{uuid}/{exit code}  success for 0, failure otherwise
+//	/ping/{uuid}/log          message only, status unchanged
+//
+// The UUID is either a device token or a ping_uuid from the device definitions.
+// GET, HEAD and POST are accepted; a POST body becomes the device message and is
+// kept in the device's log history.
+func pingHandler(cfg *config.Config, notifiers []notify.Notifier, basePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		token, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, basePath+"/ping/"), "/")
+		name, ok := lookupPingUUID(cfg, token)
+		if !ok {
+			log.Printf("Rejected ping with unknown token from %s", r.RemoteAddr)
+			w.WriteHeader(http.StatusNotFound)
+			if _, err := w.Write([]byte("Unknown token")); err != nil {
+				log.Printf("Write error: %v", err)
+			}
+			return
+		}
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHeartbeatBody))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := checkRequestSignature(cfg, name, r, raw); err != nil {
+			log.Printf("Security event: rejected ping for %s from %s: %v", name, r.RemoteAddr, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			if _, err := w.Write([]byte("Missing or invalid signature")); err != nil {
+				log.Printf("Write error: %v", err)
+			}
+			return
+		}
+
+		message := pingMessage(raw)
+		output := truncateLog(raw)
+		switch action {
+		case "":
+			log.Printf("Received ping from client: %s", name)
+			recordStatus(cfg, notifiers, name, heartbeatStatus{Message: message, Log: output})
+		case "start":
+			log.Printf("Received start signal from client: %s", name)
+			if err := dbInstance.SetStarted(name, time.Now()); err != nil {
+				log.Printf("DB update error for %s: %v", name, err)
+			}
+			broadcastDeviceTable(cfg)
+		case "fail":
+			log.Printf("Received failure signal from client: %s", name)
+			recordStatus(cfg, notifiers, name, heartbeatStatus{Failed: true, Message: message, Log: output})
+		case "log":
+			log.Printf("Received log message from client: %s", name)
+			if err := dbInstance.SetMessage(name, message); err != nil {
+				log.Printf("DB update error for %s: %v", name, err)
+			}
+			if output != "" {
+				storeLog(cfg, name, db.LogEntry{Timestamp: time.Now(), Output: output})
+			}
+			broadcastDeviceTable(cfg)
+		default:
+			code, err := strconv.Atoi(action)
+			if err != nil || code < 0 || code > 255 {
+				w.WriteHeader(http.StatusBadRequest)
+				if _, err := w.Write([]byte("Invalid ping action")); err != nil {
+					log.Printf("Write error: %v", err)
+				}
+				return
+			}
+			log.Printf("Received exit status %d from client: %s", code, name)
+			if code != 0 && message == "" {
+				message = "exit status " + action
+			}
+			recordStatus(cfg, notifiers, name, heartbeatStatus{Failed: code != 0, Message: message, Log: output})
+		}
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte("OK")); err != nil {
+			log.Printf("Write error: %v", err)
+		}
+	}
+}
+
+// lookupPingUUID resolves a ping UUID to a device name, preferring configured
+// ping_uuid values over issued device tokens.
+func lookupPingUUID(cfg *config.Config, uuid string) (string, bool) {
+	if d, ok := cfg.DeviceByPingUUID(uuid); ok {
+		return d.Name, true
+	}
+	return dbInstance.LookupToken(uuid)
+}
+
+// pingMessage returns the first non-empty line of body, shortened for display.
+func pingMessage(body []byte) string {
+	if !utf8.Valid(body) {
+		return ""
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if utf8.RuneCountInString(line) > maxPingMessage {
+			line = string([]rune(line)[:maxPingMessage]) + "…"
+		}
+		return line
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+func TestPingMessage(t *testing.T) {
+	tests := []struct {
+		body, want string
+	}{
+		{"", ""},
+		{"\n\n  backup done  \nsecond line", "backup done"},
+		{strings.Repeat("x", maxPingMessage+10), strings.Repeat("x", maxPingMessage) + "…"},
+		{"\xff\xfe", ""},
+	}
+	for _, tt := range tests {
+		if got := pingMessage([]byte(tt.body)); got != tt.want {
+			t.Errorf("pingMessage(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestHealthchecksPingAPI(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-ping.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	const uuid = "5bf66975-d4c7-4bf5-bcc8-b8d8a82ea278"
+	cfg := &config.Config{
+		TimeoutSeconds: 600,
+		Devices:        []config.Device{{Name: "backup", PingUUID: uuid}},
+	}
+	rec := &recordingNotifier{}
+	ts := httptest.NewServer(newMux(cfg, []notify.Notifier{rec}, ""))
+	defer ts.Close()
+
+	do := func(method, path, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	device := func() db.ClientHeartbeat {
+		t.Helper()
+		ch, ok := dbInstance.Get("backup")
+		if !ok {
+			t.Fatal("backup not recorded")
+		}
+		return ch
+	}
+
+	// /start before the first ping is acknowledged but stores nothing
+	if got := do(http.MethodGet, "/ping/"+uuid+"/start", ""); got != http.StatusOK {
+		t.Fatalf("start: expected 200, got %d", got)
+	}
+	if _, ok := dbInstance.Get("backup"); ok {
+		t.Error("start must not create a device")
+	}
+
+	if got := do(http.MethodHead, "/ping/"+strings.ToUpper(uuid), ""); got != http.StatusOK {
+		t.Fatalf("HEAD ping: expected 200, got %d", got)
+	}
+	if ch := device(); ch.Failed {
+		t.Errorf("expected success, got %+v", ch)
+	}
+
+	do(http.MethodGet, "/ping/"+uuid+"/start", "")
+	if device().StartedAt == nil {
+		t.Error("expected start time to be recorded")
+	}
+
+	if got := do(http.MethodPost, "/ping/"+uuid+"/fail", "rsync: connection refused\n"); got != http.StatusOK {
+		t.Fatalf("fail: expected 200, got %d", got)
+	}
+	if ch := device(); !ch.Failed || ch.Message != "rsync: connection refused" || ch.StartedAt != nil {
+		t.Errorf("unexpected state after /fail: %+v", ch)
+	}
+
+	do(http.MethodPost, "/ping/"+uuid+"/log", "retrying")
+	if ch := device(); !ch.Failed || ch.Message != "retrying" {
+		t.Errorf("/log must only change the message: %+v", ch)
+	}
+
+	do(http.MethodGet, "/ping/"+uuid+"/2", "")
+	if ch := device(); !ch.Failed || ch.Message != "exit status 2" {
+		t.Errorf("unexpected state after exit status 2: %+v", ch)
+	}
+	do(http.MethodGet, "/ping/"+uuid+"/0", "")
+	if ch := device(); ch.Failed {
+		t.Errorf("exit status 0 should recover: %+v", ch)
+	}
+	if strings.Join(rec.subjects, "|") != "Dead Man's Switch Failure|Dead Man's Switch Recovery" {
+		t.Errorf("unexpected notifications: %v", rec.subjects)
+	}
+
+	if got := do(http.MethodGet, "/ping/"+uuid+"/256", ""); got != http.StatusBadRequest {
+		t.Errorf("invalid exit status: expected 400, got %d", got)
+	}
+	if got := do(http.MethodGet, "/ping/"+uuid+"/unknown", ""); got != http.StatusBadRequest {
+		t.Errorf("unknown action: expected 400, got %d", got)
+	}
+	if got := do(http.MethodGet, "/ping/00000000-0000-0000-0000-000000000000", ""); got != http.StatusNotFound {
+		t.Errorf("unknown uuid: expected 404, got %d", got)
+	}
+	if got := do(http.MethodDelete, "/ping/"+uuid, ""); got != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: expected 405, got %d", got)
+	}
+}
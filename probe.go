@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// maxProbeBody caps how much of an HTTP probe response is matched against body_regex.
+const maxProbeBody = 1 << 20
+
+// prober checks a server-side device once. Unless it returns an error, the
+// returned status is recorded as a heartbeat.
+type prober func(ctx context.Context) (heartbeatStatus, error)
+
+// startProbes starts a polling loop for every device with a probe type, seeding a
+// record for devices not seen before. Invalid device settings are reported before
+// any loop is started.
+func startProbes(ctx context.Context, cfg *config.Config, notifiers []notify.Notifier) error {
+	type probe struct {
+		device config.Device
+		check  prober
+	}
+	var probes []probe
+	for _, d := range cfg.Devices {
+		if d.Type == "" {
+			continue
+		}
+		check, err := newProber(d)
+		if err != nil {
+			return fmt.Errorf("device %s: %w", d.Name, err)
+		}
+		probes = append(probes, probe{d, check})
+	}
+	now := time.Now()
+	for _, p := range probes {
+		// Seed a record so a device that is down from the start still times out
+		if err := dbInstance.InsertHeartbeat(db.ClientHeartbeat{Name: p.device.Name, Timestamp: now, Message: "waiting for first probe"}); err != nil {
+			log.Printf("DB update error for %s: %v", p.device.Name, err)
+		}
+		log.Printf("Probing %s device %s every %s", p.device.Type, p.device.Name, p.device.Interval())
+		go runProbe(ctx, cfg, notifiers, p.device, p.check)
+	}
+	return nil
+}
+
+// newProber returns the check for a device with a probe type.
+func newProber(d config.Device) (prober, error) {
+	switch d.Type {
+	case "http":
+		return newHTTPProber(d)
+	case "tcp":
+		if d.Address == "" {
+			return nil, errors.New("tcp probe needs an address")
+		}
+		return func(ctx context.Context) (heartbeatStatus, error) {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "tcp", d.Address)
+			if err != nil {
+				return heartbeatStatus{}, err
+			}
+			return heartbeatStatus{}, conn.Close()
+		}, nil
+	case "cert":
+		return newCertProber(d)
+	case "file":
+		return newFileProber(d)
+	default:
+		return nil, fmt.Errorf("unknown device type %q", d.Type)
+	}
+}
+
+func newHTTPProber(d config.Device) (prober, error) {
+	u, err := url.Parse(d.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("http probe needs an http(s) url, got %q", d.URL)
+	}
+	var bodyRegex *regexp.Regexp
+	if d.BodyRegex != "" {
+		if bodyRegex, err = regexp.Compile(d.BodyRegex); err != nil {
+			return nil, fmt.Errorf("body_regex: %w", err)
+		}
+	}
+	return func(ctx context.Context) (heartbeatStatus, error) {
+		var st heartbeatStatus
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.URL, nil)
+		if err != nil {
+			return st, err
+		}
+		req.Header.Set("User-Agent", "dead-mans-switch probe")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return st, err
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		if d.ExpectedStatus != 0 && resp.StatusCode != d.ExpectedStatus {
+			return st, fmt.Errorf("status %d, expected %d", resp.StatusCode, d.ExpectedStatus)
+		}
+		if d.ExpectedStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+			return st, fmt.Errorf("status %d", resp.StatusCode)
+		}
+		if bodyRegex != nil {
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+			if err != nil {
+				return st, err
+			}
+			if !bodyRegex.Match(body) {
+				return st, errors.New("response body does not match body_regex")
+			}
+		}
+		return st, nil
+	}, nil
+}
+
+// runProbe checks d immediately and then every interval until ctx is done. Each
+// completed check is recorded as a heartbeat, so timeouts, recoveries and the UI
+// work as for pushed heartbeats; a check that fails to run only updates the
+// device message, so the seeded record times out if the device never comes up.
+func runProbe(ctx context.Context, cfg *config.Config, notifiers []notify.Notifier, d config.Device, check prober) {
+	ticker := time.NewTicker(d.Interval())
+	defer ticker.Stop()
+	for {
+		probeCtx, cancel := context.WithTimeout(ctx, d.ProbeTimeout())
+		st, err := check(probeCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case errors.Is(err, errNotModified):
+			// Nothing new to record
+		case err != nil:
+			log.Printf("Probe failed for %s: %v", d.Name, err)
+			if err := dbInstance.SetMessage(d.Name, "probe failed: "+err.Error()); err != nil {
+				log.Printf("DB update error for %s: %v", d.Name, err)
+			}
+			broadcastDeviceTable(cfg)
+		default:
+			log.Printf("Probe succeeded for client: %s", d.Name)
+			recordStatus(cfg, notifiers, d.Name, st)
+			if st.ExpiresAt != nil {
+				notifyExpiry(cfg, notifiers, d)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
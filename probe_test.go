@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+func TestNewProberValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		device config.Device
+	}{
+		{"unknown type", config.Device{Type: "icmp"}},
+		{"http without url", config.Device{Type: "http"}},
+		{"http with other scheme", config.Device{Type: "http", URL: "ftp://example.com"}},
+		{"invalid regex", config.Device{Type: "http", URL: "http://example.com", BodyRegex: "("}},
+		{"tcp without address", config.Device{Type: "tcp"}},
+	}
+	for _, tt := range tests {
+		if _, err := newProber(tt.device); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	status, body := http.StatusOK, "status: healthy"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	check, err := newProber(config.Device{Type: "http", URL: srv.URL, BodyRegex: "healthy$"})
+	if err != nil {
+		t.Fatalf("newProber: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := check(ctx); err != nil {
+		t.Errorf("expected success, got %v", err)
+	}
+	body = "status: degraded"
+	if _, err := check(ctx); err == nil {
+		t.Error("expected body mismatch")
+	}
+	body, status = "status: healthy", http.StatusServiceUnavailable
+	if _, err := check(ctx); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected status error, got %v", err)
+	}
+
+	check, err = newProber(config.Device{Type: "http", URL: srv.URL, ExpectedStatus: http.StatusServiceUnavailable})
+	if err != nil {
+		t.Fatalf("newProber: %v", err)
+	}
+	if _, err := check(ctx); err != nil {
+		t.Errorf("expected configured status to succeed, got %v", err)
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := l.Addr().String()
+	check, err := newProber(config.Device{Type: "tcp", Address: addr})
+	if err != nil {
+		t.Fatalf("newProber: %v", err)
+	}
+	if _, err := check(context.Background()); err != nil {
+		t.Errorf("expected open port to succeed, got %v", err)
+	}
+	l.Close()
+	if _, err := check(context.Background()); err == nil {
+		t.Error("expected closed port to fail")
+	}
+}
+
+func TestRunProbeRecordsHeartbeats(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-probe.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	cfg := &config.Config{
+		TimeoutSeconds: 600,
+		Devices:        []config.Device{{Name: "web", Type: "http", URL: srv.URL}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := startProbes(ctx, cfg, nil); err != nil {
+		t.Fatalf("startProbes: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if ch, ok := dbInstance.Get("web"); ok && !ch.Failed && ch.Message == "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for probe heartbeat")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cfg.Devices = append(cfg.Devices, config.Device{Name: "bad", Type: "tcp"})
+	if err := startProbes(ctx, cfg, nil); err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Errorf("expected config error naming the device, got %v", err)
+	}
+}
+
+func TestRunProbeTimesOutWhenDownFromStart(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-probe-down.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{
+		TimeoutSeconds: 1,
+		Devices:        []config.Device{{Name: "web", Type: "http", URL: srv.URL}},
+	}
+	rec := &recordingNotifier{}
+	notifiers := []notify.Notifier{rec}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := startProbes(ctx, cfg, notifiers); err != nil {
+		t.Fatalf("startProbes: %v", err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for rec.count() == 0 {
+		if time.Now().After(deadline) {
+			ch, _ := dbInstance.Get("web")
+			t.Fatalf("timed out waiting for timeout notification, record %+v", ch)
+		}
+		checkTimeouts(cfg, notifiers)
+		time.Sleep(50 * time.Millisecond)
+	}
+	if rec.subjects[0] != "Dead Man's Switch Triggered" {
+		t.Errorf("expected timeout notification, got %q", rec.subjects[0])
+	}
+	if ch, _ := dbInstance.Get("web"); !ch.Missing || !strings.HasPrefix(ch.Message, "probe failed: status 500") {
+		t.Errorf("expected missing device with probe error, got %+v", ch)
+	}
+}
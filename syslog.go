@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// maxSyslogMessage is the largest accepted syslog message, on UDP and TCP.
+const maxSyslogMessage = 8192
+
+// maxSyslogLengthDigits bounds the octet-count prefix; five digits cover
+// maxSyslogMessage with room for leading zeros.
+const maxSyslogLengthDigits = 5
+
+// Syslog receiver counters, exposed under /debug/vars.
+var (
+	syslogMessagesReceived  = newCounter("syslog_messages_received")
+	syslogMessagesMalformed = newCounter("syslog_messages_malformed")
+	syslogMessagesUnmatched = newCounter("syslog_messages_unmatched")
+	syslogMessagesRejected  = newCounter("syslog_messages_rejected")
+)
+
+// syslogMessage holds the parts of an RFC 5424 or RFC 3164 message used for matching.
+type syslogMessage struct {
+	hostname string
+	appName  string
+	message  string
+}
+
+// parseSyslog parses a single RFC 5424 or RFC 3164 (BSD) syslog message.
+// Missing fields are returned empty.
+func parseSyslog(line string) (syslogMessage, error) {
+	var m syslogMessage
+	line = strings.TrimRight(line, "\r\n\x00")
+	end := strings.IndexByte(line, '>')
+	if !strings.HasPrefix(line, "<") || end < 2 || end > 4 {
+		return m, errors.New("missing priority")
+	}
+	if pri, err := strconv.Atoi(line[1:end]); err != nil || pri > 191 {
+		return m, fmt.Errorf("invalid priority %q", line[1:end])
+	}
+	rest := line[end+1:]
+	if strings.HasPrefix(rest, "1 ") {
+		return parseSyslog5424(m, rest[2:])
+	}
+	return parseSyslog3164(m, rest), nil
+}
+
+// parseSyslog5424 parses "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD [MSG]".
+func parseSyslog5424(m syslogMessage, rest string) (syslogMessage, error) {
+	fields := strings.SplitN(rest, " ", 6)
+	if len(fields) < 6 {
+		return m, errors.New("truncated RFC 5424 header")
+	}
+	m.hostname = nilValue(fields[1])
+	m.appName = nilValue(fields[2])
+	sd := fields[5]
+	if strings.HasPrefix(sd, "-") {
+		sd = sd[1:]
+	} else {
+		// Skip structured data elements, honouring escaped "]" in parameter values
+		for strings.HasPrefix(sd, "[") {
+			i, escaped := 1, false
+			for ; i < len(sd); i++ {
+				if escaped {
+					escaped = false
+				} else if sd[i] == '\\' {
+					escaped = true
+				} else if sd[i] == ']' {
+					break
+				}
+			}
+			if i == len(sd) {
+				return m, errors.New("unterminated structured data")
+			}
+			sd = sd[i+1:]
+		}
+	}
+	m.message = strings.TrimPrefix(strings.TrimPrefix(sd, " "), "\ufeff")
+	return m, nil
+}
+
+// parseSyslog3164 parses "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG". Senders often
+// omit the timestamp or hostname, so anything unrecognised ends up in the message.
+func parseSyslog3164(m syslogMessage, rest string) syslogMessage {
+	if len(rest) > 16 && rest[15] == ' ' {
+		if _, err := time.Parse(time.Stamp, rest[:15]); err == nil {
+			rest = rest[16:]
+			if host, after, ok := strings.Cut(rest, " "); ok && !strings.HasSuffix(host, ":") {
+				m.hostname, rest = host, after
+			}
+		}
+	}
+	if tag, msg, ok := strings.Cut(rest, ": "); ok && tag != "" && !strings.Contains(tag, " ") {
+		if i := strings.IndexByte(tag, '['); i > 0 {
+			tag = tag[:i]
+		}
+		m.appName, rest = tag, msg
+	}
+	m.message = rest
+	return m
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// syslogRule is a config.SyslogRule with compiled patterns.
+type syslogRule struct {
+	device   string
+	hostname *regexp.Regexp
+	appName  *regexp.Regexp
+	message  *regexp.Regexp
+	failure  *regexp.Regexp
+}
+
+func compileSyslogRules(rules []config.SyslogRule) ([]syslogRule, error) {
+	compiled := make([]syslogRule, 0, len(rules))
+	for i, r := range rules {
+		c := syslogRule{device: r.Device}
+		if c.device == "" {
+			c.device = "{{hostname}}"
+		}
+		for _, p := range []struct {
+			expr string
+			re   **regexp.Regexp
+		}{
+			{r.Hostname, &c.hostname},
+			{r.AppName, &c.appName},
+			{r.Message, &c.message},
+			{r.FailurePattern, &c.failure},
+		} {
+			if p.expr == "" {
+				continue
+			}
+			re, err := regexp.Compile(p.expr)
+			if err != nil {
+				return nil, fmt.Errorf("syslog rule %d: %w", i+1, err)
+			}
+			*p.re = re
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// match returns the device name and status for m, or ok=false if the rule does not apply.
+func (r syslogRule) match(m syslogMessage) (name string, st heartbeatStatus, ok bool) {
+	if !matchOptional(r.hostname, m.hostname) || !matchOptional(r.appName, m.appName) || !matchOptional(r.message, m.message) {
+		return "", st, false
+	}
+	name = strings.NewReplacer("{{hostname}}", m.hostname, "{{app_name}}", m.appName).Replace(r.device)
+	if name == "" {
+		return "", st, false
+	}
+	if r.failure != nil && r.failure.MatchString(m.message) {
+		st.Failed = true
+		st.Message = pingMessage([]byte(m.message))
+	}
+	return name, st, true
+}
+
+func matchOptional(re *regexp.Regexp, s string) bool {
+	return re == nil || re.MatchString(s)
+}
+
+// handleSyslogMessage records a heartbeat for the first rule matching data.
+// Syslog carries no credentials, so devices that need a signature or, with
+// require_token, a token are rejected like unauthenticated heartbeats.
+func handleSyslogMessage(cfg *config.Config, notifiers []notify.Notifier, rules []syslogRule, data string, addr net.Addr) {
+	syslogMessagesReceived.Add(1)
+	m, err := parseSyslog(data)
+	if err != nil {
+		syslogMessagesMalformed.Add(1)
+		log.Printf("Malformed syslog message from %s: %v", addr, err)
+		return
+	}
+	if m.hostname == "" {
+		if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+			m.hostname = host
+		}
+	}
+	for _, r := range rules {
+		if name, st, ok := r.match(m); ok {
+			if err := authorizeCompact(cfg, name, "", "", ""); err != nil {
+				syslogMessagesRejected.Add(1)
+				log.Printf("Security event: rejected syslog heartbeat for %s from %s: %v", name, addr, err)
+				return
+			}
+			log.Printf("Received syslog heartbeat from client: %s (failed: %t)", name, st.Failed)
+			recordStatus(cfg, notifiers, name, st)
+			return
+		}
+	}
+	syslogMessagesUnmatched.Add(1)
+}
+
+// serveSyslogUDP reads one syslog message per datagram from conn until it is closed.
+func serveSyslogUDP(conn net.PacketConn, cfg *config.Config, notifiers []notify.Notifier, rules []syslogRule) {
+	buf := make([]byte, maxSyslogMessage)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Syslog UDP read error: %v", err)
+			continue
+		}
+		handleSyslogMessage(cfg, notifiers, rules, string(buf[:n]), addr)
+	}
+}
+
+// serveSyslogTCP accepts syslog connections on l until it is closed.
+func serveSyslogTCP(l net.Listener, cfg *config.Config, notifiers []notify.Notifier, rules []syslogRule) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Syslog TCP accept error: %v", err)
+			continue
+		}
+		go func() {
+			defer func() {
+				_ = conn.Close()
+			}()
+			r := bufio.NewReaderSize(conn, maxSyslogMessage)
+			for {
+				_ = conn.SetReadDeadline(time.Now().Add(10 * time.Minute))
+				frame, err := readSyslogFrame(r)
+				if err != nil {
+					if !errors.Is(err, io.EOF) {
+						log.Printf("Syslog TCP read error from %s: %v", conn.RemoteAddr(), err)
+					}
+					return
+				}
+				handleSyslogMessage(cfg, notifiers, rules, frame, conn.RemoteAddr())
+			}
+		}()
+	}
+}
+
+// readSyslogFrame reads one message using octet counting (RFC 6587 "<len> <msg>")
+// or, if the frame does not start with a digit, newline-delimited framing.
+func readSyslogFrame(r *bufio.Reader) (string, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if first[0] >= '0' && first[0] <= '9' {
+		var prefix []byte
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			if c == ' ' {
+				break
+			}
+			if c < '0' || c > '9' || len(prefix) == maxSyslogLengthDigits {
+				return "", fmt.Errorf("invalid frame length %q", append(prefix, c))
+			}
+			prefix = append(prefix, c)
+		}
+		n, err := strconv.Atoi(string(prefix))
+		if err != nil || n <= 0 || n > maxSyslogMessage {
+			return "", fmt.Errorf("invalid frame length %q", prefix)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", errors.New("message too large")
+	}
+	if err != nil && (len(line) == 0 || !errors.Is(err, io.EOF)) {
+		return "", err
+	}
+	return string(line), nil
+}
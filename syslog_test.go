@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+func TestParseSyslog(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    syslogMessage
+		wantErr bool
+	}{
+		{"rfc5424", "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - BOM'su root' failed\n",
+			syslogMessage{hostname: "mymachine.example.com", appName: "evntslog", message: "BOM'su root' failed"}, false},
+		{"rfc5424 structured data", `<165>1 2003-10-11T22:14:15.003Z host app 123 - [exampleSDID@32473 iut="3" note="a\]b"][x@1 y="z"] link up`,
+			syslogMessage{hostname: "host", appName: "app", message: "link up"}, false},
+		{"rfc5424 nil values", "<14>1 - - - - - -",
+			syslogMessage{}, false},
+		{"rfc3164", "<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed for lonvick",
+			syslogMessage{hostname: "mymachine", appName: "su", message: "'su root' failed for lonvick"}, false},
+		{"rfc3164 without hostname", "<34>Oct  1 22:14:15 su: link down",
+			syslogMessage{appName: "su", message: "link down"}, false},
+		{"rfc3164 bare message", "<13>hello world",
+			syslogMessage{message: "hello world"}, false},
+		{"no priority", "hello", syslogMessage{}, true},
+		{"invalid priority", "<999>hello", syslogMessage{}, true},
+		{"truncated rfc5424", "<14>1 2003-10-11T22:14:15Z host", syslogMessage{}, true},
+		{"unterminated structured data", `<14>1 - host app - - [x@1 y="z"`, syslogMessage{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSyslog(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSyslog(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseSyslog(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogRules(t *testing.T) {
+	if _, err := compileSyslogRules([]config.SyslogRule{{Hostname: "("}}); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+	rules, err := compileSyslogRules([]config.SyslogRule{
+		{Device: "core-{{app_name}}", Hostname: `^core\d+$`, FailurePattern: `(?i)link down`},
+		{AppName: "^cron$"},
+	})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	tests := []struct {
+		msg    syslogMessage
+		name   string
+		failed bool
+		ok     bool
+	}{
+		{syslogMessage{hostname: "core1", appName: "ifmgr", message: "Link up"}, "core-ifmgr", false, true},
+		{syslogMessage{hostname: "core1", appName: "ifmgr", message: "LINK DOWN on ge-0/0/1"}, "core-ifmgr", true, true},
+		{syslogMessage{hostname: "nas", appName: "cron", message: "job done"}, "nas", false, true},
+		{syslogMessage{hostname: "nas", appName: "sshd", message: "login"}, "", false, false},
+	}
+	for _, tt := range tests {
+		var name string
+		var st heartbeatStatus
+		ok := false
+		for _, r := range rules {
+			if name, st, ok = r.match(tt.msg); ok {
+				break
+			}
+		}
+		if ok != tt.ok || name != tt.name || st.Failed != tt.failed {
+			t.Errorf("%+v: got %q failed=%v ok=%v", tt.msg, name, st.Failed, ok)
+		}
+	}
+}
+
+func TestReadSyslogFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"octet counted", "5 hello", "hello", false},
+		{"newline", "<13>hello\nnext", "<13>hello\n", false},
+		{"zero length", "0 ", "", true},
+		{"too large", "8193 x", "", true},
+		{"non-digit prefix", "12a hello", "", true},
+		{"unbounded prefix", strings.Repeat("1", 1<<20), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReaderSize(strings.NewReader(tt.input), maxSyslogMessage)
+			got, err := readSyslogFrame(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readSyslogFrame(%.20q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readSyslogFrame(%.20q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeSyslog(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-syslog.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{TimeoutSeconds: 600}
+	rules, err := compileSyslogRules([]config.SyslogRule{{Device: "{{app_name}}", FailurePattern: "ERROR"}})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	rec := &recordingNotifier{}
+	notifiers := []notify.Notifier{rec}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	defer conn.Close()
+	go serveSyslogUDP(conn, cfg, notifiers, rules)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen tcp: %v", err)
+	}
+	defer l.Close()
+	go serveSyslogTCP(l, cfg, notifiers, rules)
+
+	udp, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("dial udp: %v", err)
+	}
+	defer udp.Close()
+	if _, err := udp.Write([]byte("<14>Oct 11 22:14:15 switch1 udpapp: port up")); err != nil {
+		t.Fatalf("write udp: %v", err)
+	}
+
+	tcp, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial tcp: %v", err)
+	}
+	defer tcp.Close()
+	framed := "<11>1 - router1 tcpapp - - - ERROR fan failure"
+	if _, err := fmt.Fprintf(tcp, "<14>Oct 11 22:14:16 router1 lineapp: hello\n%d %s", len(framed), framed); err != nil {
+		t.Fatalf("write tcp: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		beats, _ := dbInstance.GetAllHeartbeats()
+		if len(beats) == 3 {
+			if beats["udpapp"].Failed || beats["lineapp"].Failed {
+				t.Errorf("expected successful heartbeats, got %+v", beats)
+			}
+			if ch := beats["tcpapp"]; !ch.Failed || ch.Message != "ERROR fan failure" {
+				t.Errorf("expected tcpapp failure, got %+v", ch)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for syslog heartbeats, got %+v", beats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSyslogRejectsProtectedDevices(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-syslog-protected.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	rules, err := compileSyslogRules([]config.SyslogRule{{}})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	addr := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 514}
+	cfg := &config.Config{TimeoutSeconds: 600, Devices: []config.Device{{Name: "vault", HMACSecret: "s3cret"}}}
+
+	rejected := syslogMessagesRejected.Value()
+	// The sender chooses the hostname, so it must not keep HMAC devices alive
+	handleSyslogMessage(cfg, nil, rules, "<14>Oct 11 22:14:15 vault app: spoofed", addr)
+	if _, ok := dbInstance.Get("vault"); ok {
+		t.Error("syslog heartbeat recorded for device with hmac_secret")
+	}
+	handleSyslogMessage(cfg, nil, rules, "<14>Oct 11 22:14:15 switch1 app: port up", addr)
+	if _, ok := dbInstance.Get("switch1"); !ok {
+		t.Error("syslog heartbeat for unprotected device not recorded")
+	}
+
+	// With require_token, no device can authenticate over syslog
+	cfg.RequireToken = true
+	handleSyslogMessage(cfg, nil, rules, "<14>Oct 11 22:14:15 switch2 app: port up", addr)
+	if _, ok := dbInstance.Get("switch2"); ok {
+		t.Error("syslog heartbeat recorded despite require_token")
+	}
+	if got := syslogMessagesRejected.Value() - rejected; got != 2 {
+		t.Errorf("expected 2 rejected messages, got %d", got)
+	}
+}
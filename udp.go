@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// maxUDPPacket is the largest accepted heartbeat datagram; longer packets are malformed.
+const maxUDPPacket = 512
+
+// UDP listener counters, exposed under /debug/vars.
+var (
+	udpPacketsReceived  = newCounter("udp_packets_received")
+	udpPacketsMalformed = newCounter("udp_packets_malformed")
+	udpPacketsRejected  = newCounter("udp_packets_rejected")
+)
+
+// udpPacket is a parsed heartbeat datagram of the form
+// "<name> [token=<token>] [ts=<unix seconds> sig=<hex hmac>]".
+type udpPacket struct {
+	name      string
+	token     string
+	timestamp string
+	signature string
+}
+
+func parseUDPPacket(data []byte) (udpPacket, error) {
+	var p udpPacket
+	if len(data) > maxUDPPacket {
+		return p, errors.New("packet too large")
+	}
+	if !utf8.Valid(data) {
+		return p, errors.New("packet is not valid UTF-8")
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return p, errors.New("empty packet")
+	}
+	p.name = fields[0]
+	for _, f := range fields[1:] {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok || v == "" {
+			return p, fmt.Errorf("invalid field %q", f)
+		}
+		switch k {
+		case "token":
+			p.token = v
+		case "ts":
+			p.timestamp = v
+		case "sig":
+			p.signature = v
+		default:
+			return p, fmt.Errorf("unknown field %q", k)
+		}
+	}
+	if (p.timestamp == "") != (p.signature == "") {
+		return p, errors.New("ts and sig must be sent together")
+	}
+	return p, nil
+}
+
+// serveUDP reads heartbeat datagrams from conn until it is closed.
+func serveUDP(conn net.PacketConn, cfg *config.Config, notifiers []notify.Notifier) {
+	// One spare byte lets us detect oversized datagrams instead of silently truncating them
+	buf := make([]byte, maxUDPPacket+1)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("UDP read error: %v", err)
+			continue
+		}
+		udpPacketsReceived.Add(1)
+		handleUDPPacket(cfg, notifiers, buf[:n], addr)
+	}
+}
+
+func handleUDPPacket(cfg *config.Config, notifiers []notify.Notifier, data []byte, addr net.Addr) {
+	p, err := parseUDPPacket(data)
+	if err != nil {
+		udpPacketsMalformed.Add(1)
+		log.Printf("Malformed UDP packet from %s: %v", addr, err)
+		return
+	}
+	if err := authorizeCompact(cfg, p.name, p.token, p.timestamp, p.signature); err != nil {
+		udpPacketsRejected.Add(1)
+		log.Printf("Security event: rejected UDP heartbeat for %s from %s: %v", p.name, addr, err)
+		return
+	}
+	log.Printf("Received UDP heartbeat from client: %s", p.name)
+	recordHeartbeat(cfg, notifiers, p.name)
+}
+
+// authorizeCompact applies the HMAC and token rules to heartbeats from transports
+// without request headers or bodies, such as UDP or MQTT. The signed body is the
+// device name, i.e. the signature covers "<timestamp>.<name>".
+func authorizeCompact(cfg *config.Config, name, token, timestamp, signature string) error {
+	if d, ok := cfg.Device(name); ok && d.HMACSecret != "" {
+		if err := verifySignature(d.HMACSecret, []byte(name), timestamp, signature, cfg.ReplayWindow(), time.Now()); err != nil {
+			return fmt.Errorf("hmac: %w", err)
+		}
+	}
+	if cfg.RequireToken && !dbInstance.VerifyToken(name, token) {
+		return errors.New("missing or invalid device token")
+	}
+	return nil
+}
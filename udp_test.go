@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+)
+
+func TestParseUDPPacket(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    udpPacket
+		wantErr bool
+	}{
+		{"name only", "sensor1", udpPacket{name: "sensor1"}, false},
+		{"trailing newline", "sensor1\n", udpPacket{name: "sensor1"}, false},
+		{"with token", "sensor1 token=abc", udpPacket{name: "sensor1", token: "abc"}, false},
+		{"signed", "sensor1 ts=1700000000 sig=deadbeef", udpPacket{name: "sensor1", timestamp: "1700000000", signature: "deadbeef"}, false},
+		{"empty", "   ", udpPacket{}, true},
+		{"unknown field", "sensor1 foo=bar", udpPacket{}, true},
+		{"field without value", "sensor1 token=", udpPacket{}, true},
+		{"ts without sig", "sensor1 ts=1700000000", udpPacket{}, true},
+		{"invalid utf8", "sensor\xff", udpPacket{}, true},
+		{"too large", strings.Repeat("a", maxUDPPacket+1), udpPacket{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUDPPacket([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUDPPacket(%q) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseUDPPacket(%q) = %+v, want %+v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeUDP(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-udp.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{
+		TimeoutSeconds:          600,
+		HMACReplayWindowSeconds: 300,
+		Devices:                 []config.Device{{Name: "signed", HMACSecret: "s3cret"}},
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	go serveUDP(conn, cfg, nil)
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	malformed := udpPacketsMalformed.Value()
+	rejected := udpPacketsRejected.Value()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	packets := []string{
+		"plain",
+		"bad foo=bar",
+		"signed",
+		"signed ts=" + ts + " sig=" + computeSignature("s3cret", ts, []byte("signed")),
+	}
+	for _, p := range packets {
+		if _, err := client.Write([]byte(p)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, plainOK := dbInstance.Get("plain")
+		_, signedOK := dbInstance.Get("signed")
+		if plainOK && signedOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("heartbeats not recorded (plain=%v signed=%v)", plainOK, signedOK)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := udpPacketsMalformed.Value() - malformed; got != 1 {
+		t.Errorf("expected 1 malformed packet, got %d", got)
+	}
+	if got := udpPacketsRejected.Value() - rejected; got != 1 {
+		t.Errorf("expected 1 rejected packet, got %d", got)
+	}
+	if _, ok := dbInstance.Get("bad"); ok {
+		t.Error("malformed packet must not be recorded")
+	}
+}
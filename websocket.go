@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// agentPingInterval is how often the server pings connected agents to detect
+// connections that died without a close frame.
+var agentPingInterval = 30 * time.Second
+
+// agentConns counts open agent connections per device, so a device stays
+// connected while an old connection is still being torn down after a reconnect.
+var agentConns = struct {
+	sync.Mutex
+	count map[string]int
+}{count: map[string]int{}}
+
+// agentSocketHandler serves GET /ws/agent/{name}. The device counts as alive for
+// as long as the WebSocket stays open; after a disconnect the monitor waits for
+// the configured grace period before marking it missing.
+func agentSocketHandler(cfg *config.Config, notifiers []notify.Notifier, basePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, basePath+"/ws/agent/")
+		if name == "" || strings.Contains(name, "/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		token := bearerToken(r)
+		if token == "" {
+			// Browser WebSocket clients cannot set an Authorization header
+			token = r.URL.Query().Get("token")
+		}
+		if err := authorizeCompact(cfg, name, token, r.Header.Get(timestampHeader), r.Header.Get(signatureHeader)); err != nil {
+			log.Printf("Security event: rejected WebSocket agent %s from %s: %v", name, r.RemoteAddr, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			if _, err := w.Write([]byte("Missing or invalid device token")); err != nil {
+				log.Printf("Write error: %v", err)
+			}
+			return
+		}
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			log.Printf("WebSocket accept error for %s: %v", name, err)
+			return
+		}
+		defer func() {
+			_ = conn.CloseNow()
+		}()
+
+		log.Printf("WebSocket agent connected: %s", name)
+		agentConnected(cfg, notifiers, name)
+		defer agentDisconnected(cfg, name)
+
+		// Agents are not expected to send data; CloseRead handles control frames
+		ctx := conn.CloseRead(r.Context())
+		ticker := time.NewTicker(agentPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pingCtx, cancel := context.WithTimeout(ctx, agentPingInterval/2)
+				err := conn.Ping(pingCtx)
+				cancel()
+				if err != nil {
+					log.Printf("WebSocket agent %s stopped answering pings: %v", name, err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// agentConnected records a heartbeat for the named agent, with the usual recovery
+// notification, and marks it connected.
+func agentConnected(cfg *config.Config, notifiers []notify.Notifier, name string) {
+	recordHeartbeat(cfg, notifiers, name)
+	agentConns.Lock()
+	defer agentConns.Unlock()
+	agentConns.count[name]++
+	if agentConns.count[name] == 1 {
+		if err := dbInstance.SetConnected(name, true, time.Now()); err != nil {
+			log.Printf("SetConnected error for %s: %v", name, err)
+		}
+		broadcastDeviceTable(cfg)
+	}
+}
+
+// agentDisconnected marks the named agent disconnected once its last connection closed.
+func agentDisconnected(cfg *config.Config, name string) {
+	agentConns.Lock()
+	defer agentConns.Unlock()
+	agentConns.count[name]--
+	if agentConns.count[name] > 0 {
+		return
+	}
+	delete(agentConns.count, name)
+	log.Printf("WebSocket agent disconnected: %s", name)
+	if err := dbInstance.SetConnected(name, false, time.Now()); err != nil {
+		log.Printf("SetConnected error for %s: %v", name, err)
+	}
+	broadcastDeviceTable(cfg)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/config"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/db"
+	"github.com/crashlooping/dead-mans-switch/dead-mans-switch/notify"
+)
+
+// waitAgentClosed waits until the handler of the named agent's last connection
+// has finished, so it cannot touch dbInstance after the test replaced it.
+func waitAgentClosed(t *testing.T, name string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		agentConns.Lock()
+		n := agentConns.count[name]
+		agentConns.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("agent %s still has %d open connections", name, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAgentWebSocket(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-ws.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{TimeoutSeconds: 600, WebSocketGraceSeconds: 60}
+	rec := &recordingNotifier{}
+	notifiers := []notify.Notifier{rec}
+	ts := httptest.NewServer(newMux(cfg, notifiers, ""))
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/agent/agent1"
+
+	waitFor := func(desc string, cond func(db.ClientHeartbeat) bool) db.ClientHeartbeat {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			ch, _ := dbInstance.Get("agent1")
+			if cond(ch) {
+				return ch
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s, got %+v", desc, ch)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	ctx := context.Background()
+	conn, _, err := websocket.Dial(ctx, wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	ch := waitFor("connect", func(ch db.ClientHeartbeat) bool { return ch.ConnectedSince != nil })
+	if !ch.Connection {
+		t.Error("expected device to be marked connection-based")
+	}
+	if html := generateDeviceTable(cfg, map[string]db.ClientHeartbeat{"agent1": ch}); !strings.Contains(html, "connected since") {
+		t.Error("expected 'connected since' in device table")
+	}
+
+	// A connected agent never times out, even with a stale timestamp
+	old := ch
+	old.Timestamp = time.Now().Add(-time.Hour)
+	if err := dbInstance.PutHeartbeat(old); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	checkTimeouts(cfg, notifiers)
+	if ch, _ := dbInstance.Get("agent1"); ch.Missing {
+		t.Error("connected agent must not be marked missing")
+	}
+
+	_ = conn.Close(websocket.StatusNormalClosure, "")
+	ch = waitFor("disconnect", func(ch db.ClientHeartbeat) bool { return ch.ConnectedSince == nil })
+	if time.Since(ch.Timestamp) > time.Minute {
+		t.Errorf("disconnect should refresh last seen, got %v", ch.Timestamp)
+	}
+
+	// Within the grace period nothing happens
+	checkTimeouts(cfg, notifiers)
+	if ch, _ := dbInstance.Get("agent1"); ch.Missing {
+		t.Error("agent must not be missing within the grace period")
+	}
+
+	// After the grace period the usual timeout logic applies
+	ch.Timestamp = time.Now().Add(-2 * time.Minute)
+	if err := dbInstance.PutHeartbeat(ch); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	checkTimeouts(cfg, notifiers)
+	if ch, _ := dbInstance.Get("agent1"); !ch.Missing {
+		t.Error("agent should be missing after the grace period")
+	}
+	if rec.count() != 1 || rec.subjects[0] != "Dead Man's Switch Triggered" {
+		t.Errorf("expected one timeout notification, got %v", rec.subjects)
+	}
+
+	// Reconnecting sends the recovery notification
+	conn, _, err = websocket.Dial(ctx, wsURL, nil)
+	if err != nil {
+		t.Fatalf("redial: %v", err)
+	}
+	defer waitAgentClosed(t, "agent1")
+	defer conn.CloseNow()
+	waitFor("reconnect", func(ch db.ClientHeartbeat) bool { return ch.ConnectedSince != nil && !ch.Missing })
+	if rec.count() != 2 || rec.subjects[1] != "Dead Man's Switch Recovery" {
+		t.Errorf("expected recovery notification, got %v", rec.subjects)
+	}
+}
+
+func TestPushedHeartbeatClearsAgentConnection(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-ws-push.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{TimeoutSeconds: 600, WebSocketGraceSeconds: 60}
+	now := time.Now()
+	if err := dbInstance.UpdateHeartbeat("agent1", now, false); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if err := dbInstance.SetConnected("agent1", true, now); err != nil {
+		t.Fatalf("set connected: %v", err)
+	}
+
+	// While connected, pushed heartbeats keep the connection
+	recordHeartbeat(cfg, nil, "agent1")
+	if ch, _ := dbInstance.Get("agent1"); !ch.Connection || ch.ConnectedSince == nil {
+		t.Errorf("heartbeat dropped a live connection: %+v", ch)
+	}
+
+	if err := dbInstance.SetConnected("agent1", false, now); err != nil {
+		t.Fatalf("set disconnected: %v", err)
+	}
+	recordHeartbeat(cfg, nil, "agent1")
+	ch, _ := dbInstance.Get("agent1")
+	if ch.Connection {
+		t.Fatalf("expected pushed heartbeat to clear the connection, got %+v", ch)
+	}
+
+	// Past the grace period but within timeout_seconds the device is not missing
+	ch.Timestamp = time.Now().Add(-2 * time.Minute)
+	if err := dbInstance.PutHeartbeat(ch); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	checkTimeouts(cfg, nil)
+	if ch, _ := dbInstance.Get("agent1"); ch.Missing {
+		t.Error("device that switched to pushed heartbeats timed out after the grace period")
+	}
+}
+
+func TestAgentWebSocketRequiresToken(t *testing.T) {
+	var err error
+	dbInstance, err = db.Open(filepath.Join(t.TempDir(), "test-ws-token.db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer dbInstance.Close()
+
+	cfg := &config.Config{TimeoutSeconds: 600, RequireToken: true}
+	ts := httptest.NewServer(newMux(cfg, nil, ""))
+	defer ts.Close()
+	if err := dbInstance.SetToken("agent1", "agent-token"); err != nil {
+		t.Fatalf("set token: %v", err)
+	}
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws/agent/agent1"
+
+	_, resp, err := websocket.Dial(context.Background(), wsURL, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %v", err)
+	}
+	conn, _, err := websocket.Dial(context.Background(), wsURL, &websocket.DialOptions{
+		HTTPHeader: http.Header{"Authorization": {"Bearer agent-token"}},
+	})
+	if err != nil {
+		t.Fatalf("dial with token: %v", err)
+	}
+	// Let the handler register the connection before closing it
+	deadline := time.Now().Add(2 * time.Second)
+	for ch, _ := dbInstance.Get("agent1"); ch.ConnectedSince == nil; ch, _ = dbInstance.Get("agent1") {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the agent to connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn.CloseNow()
+	waitAgentClosed(t, "agent1")
+}